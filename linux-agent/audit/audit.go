@@ -0,0 +1,250 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Entry is one audited request, written as a single JSON line.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remoteAddr"`
+	Identity   string    `json:"identity"`
+	Method     string    `json:"method"`
+	Endpoint   string    `json:"endpoint"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	LatencyMs  float64   `json:"latencyMs"`
+}
+
+// Query filters entries returned by Logger.Query. Zero values match
+// everything.
+type Query struct {
+	Since    time.Time
+	Until    time.Time
+	Identity string
+	Limit    int
+}
+
+// rotateRetryInterval is how long the logger keeps appending to a full
+// file after a failed rotation before it tries again.
+const rotateRetryInterval = time.Minute
+
+// Logger appends audit entries to a JSON Lines file and rotates it once it
+// grows past maxSize. Rotated files are named <path>.1 (newest) through
+// <path>.<maxFiles>; older ones are deleted.
+type Logger struct {
+	mu          sync.Mutex
+	path        string
+	maxSize     int64
+	maxFiles    int
+	file        *os.File
+	size        int64
+	rotateAfter time.Time // Set after a failed rotation
+}
+
+// NewLogger opens (or creates) the audit log at path.
+func NewLogger(path string, maxSize int64, maxFiles int) (*Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+
+	l := &Logger{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Logger) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// Record appends an entry, rotating the file first if it is full. If the
+// rotation fails (the directory is no longer writable after dropping
+// privileges, say) the entry is still appended to the current file and
+// the rotation error is returned; rotation is retried a minute later.
+func (l *Logger) Record(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return fmt.Errorf("audit log closed")
+	}
+
+	var rotateErr error
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize && !time.Now().Before(l.rotateAfter) {
+		if err := l.rotate(); err != nil {
+			l.rotateAfter = time.Now().Add(rotateRetryInterval)
+			rotateErr = fmt.Errorf("rotate audit log: %w", err)
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+// rotate shifts <path>.N to <path>.N+1, dropping anything beyond maxFiles,
+// and starts a fresh file. The current file stays open until the new one
+// is, so a failure at any step leaves the logger writing where it was.
+// Caller must hold l.mu.
+func (l *Logger) rotate() error {
+	if l.maxFiles < 1 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		os.Remove(l.rotatedPath(l.maxFiles))
+		for i := l.maxFiles - 1; i >= 1; i-- {
+			os.Rename(l.rotatedPath(i), l.rotatedPath(i+1))
+		}
+		// The file is already gone if a previous rotation failed to reopen
+		if err := os.Rename(l.path, l.rotatedPath(1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	old := l.file
+	if err := l.open(); err != nil {
+		return err
+	}
+	old.Close()
+	return nil
+}
+
+func (l *Logger) rotatedPath(n int) string {
+	return l.path + "." + strconv.Itoa(n)
+}
+
+// Close flushes and closes the current file.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Query scans the current and rotated files and returns matching entries,
+// newest first. Files are read line by line, newest file first, keeping
+// only the newest Limit matches, and older files are skipped once the
+// limit is reached or a file holds nothing after Since.
+func (l *Logger) Query(q Query) ([]Entry, error) {
+	l.mu.Lock()
+	paths := []string{l.path}
+	for i := 1; i <= l.maxFiles; i++ {
+		paths = append(paths, l.rotatedPath(i))
+	}
+	l.mu.Unlock()
+
+	var results []Entry
+	for _, path := range paths {
+		keep := 0
+		if q.Limit > 0 {
+			if keep = q.Limit - len(results); keep <= 0 {
+				break
+			}
+		}
+		entries, newest, err := readEntries(path, q, keep)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		// Files are in write order
+		for i := len(entries) - 1; i >= 0; i-- {
+			results = append(results, entries[i])
+		}
+		if !newest.IsZero() && newest.Before(q.Since) {
+			break
+		}
+	}
+
+	// Entries written around a clock step can be out of order
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Time.After(results[j].Time)
+	})
+	return results, nil
+}
+
+// readEntries returns the entries in path that match q, in file order, and
+// the time of the newest entry in the file. If keep is positive only the
+// last keep matches are returned.
+func readEntries(path string, q Query, keep int) ([]Entry, time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	var newest time.Time
+	next := 0 // Oldest kept match once entries is full
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // Skip partially written lines
+		}
+		if entry.Time.After(newest) {
+			newest = entry.Time
+		}
+		if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && entry.Time.After(q.Until) {
+			continue
+		}
+		if q.Identity != "" && entry.Identity != q.Identity {
+			continue
+		}
+		if keep > 0 && len(entries) == keep {
+			entries[next] = entry
+			next = (next + 1) % keep
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	ordered := make([]Entry, 0, len(entries))
+	ordered = append(ordered, entries[next:]...)
+	ordered = append(ordered, entries[:next]...)
+	return ordered, newest, scanner.Err()
+}
@@ -0,0 +1,167 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var baseTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// testEntry returns the i-th entry of a test log. Entries have the same
+// length, so a size limit is a whole number of lines.
+func testEntry(i int) Entry {
+	identity := "mac"
+	if i%2 == 1 {
+		identity = "cli"
+	}
+	return Entry{
+		Time:       baseTime.Add(time.Duration(i) * time.Second),
+		RemoteAddr: "192.0.2.1:50000",
+		Identity:   identity,
+		Method:     "GET",
+		Endpoint:   "/v1/stats",
+		Status:     200,
+		Bytes:      1000,
+		LatencyMs:  1.5,
+	}
+}
+
+func lineSize(t *testing.T) int64 {
+	t.Helper()
+	line, err := json.Marshal(testEntry(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int64(len(line)) + 1
+}
+
+// writeLog records entries 0 to n-1 with room for linesPerFile lines per
+// file (0 for no limit).
+func writeLog(t *testing.T, path string, n, linesPerFile, maxFiles int) *Logger {
+	t.Helper()
+	l, err := NewLogger(path, int64(linesPerFile)*lineSize(t), maxFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	for i := 0; i < n; i++ {
+		if err := l.Record(testEntry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return -1
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestLoggerRotation(t *testing.T) {
+	tests := []struct {
+		name         string
+		records      int
+		linesPerFile int
+		maxFiles     int
+		want         []int // Lines in the current file, .1, .2, ...; -1 if missing
+	}{
+		{name: "no limit", records: 10, linesPerFile: 0, maxFiles: 2, want: []int{10, -1}},
+		{name: "exactly full", records: 3, linesPerFile: 3, maxFiles: 2, want: []int{3, -1}},
+		{name: "one past full", records: 4, linesPerFile: 3, maxFiles: 2, want: []int{1, 3, -1}},
+		{name: "oldest file dropped", records: 10, linesPerFile: 3, maxFiles: 2, want: []int{1, 3, 3, -1}},
+		{name: "no rotated files kept", records: 7, linesPerFile: 3, maxFiles: 0, want: []int{1, -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			writeLog(t, path, tt.records, tt.linesPerFile, tt.maxFiles)
+
+			for i, want := range tt.want {
+				file := path
+				if i > 0 {
+					file = fmt.Sprintf("%s.%d", path, i)
+				}
+				if got := countLines(t, file); got != want {
+					t.Errorf("%s: %d lines, want %d", filepath.Base(file), got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoggerQuery(t *testing.T) {
+	at := func(i int) time.Time { return baseTime.Add(time.Duration(i) * time.Second) }
+
+	// Entries 3 to 9 survive: 9 in audit.log, 6-8 in .1, 3-5 in .2
+	tests := []struct {
+		name  string
+		query Query
+		want  []int // Entry numbers, newest first
+	}{
+		{name: "everything", query: Query{}, want: []int{9, 8, 7, 6, 5, 4, 3}},
+		{name: "limit within the current file", query: Query{Limit: 1}, want: []int{9}},
+		{name: "limit across files", query: Query{Limit: 5}, want: []int{9, 8, 7, 6, 5}},
+		{name: "limit larger than the log", query: Query{Limit: 100}, want: []int{9, 8, 7, 6, 5, 4, 3}},
+		{name: "since", query: Query{Since: at(5)}, want: []int{9, 8, 7, 6, 5}},
+		{name: "since within the current file", query: Query{Since: at(9)}, want: []int{9}},
+		{name: "since after everything", query: Query{Since: at(10)}, want: nil},
+		{name: "until", query: Query{Until: at(4)}, want: []int{4, 3}},
+		{name: "since and until", query: Query{Since: at(4), Until: at(7)}, want: []int{7, 6, 5, 4}},
+		{name: "identity", query: Query{Identity: "cli"}, want: []int{9, 7, 5, 3}},
+		{name: "identity and limit", query: Query{Identity: "mac", Limit: 2}, want: []int{8, 6}},
+		{name: "since and limit", query: Query{Since: at(4), Limit: 3}, want: []int{9, 8, 7}},
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := writeLog(t, path, 10, 3, 2)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := l.Query(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, e := range entries {
+				got = append(got, int(e.Time.Sub(baseTime)/time.Second))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Query(%+v) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerQuerySkipsPartialLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := writeLog(t, path, 2, 0, 1)
+
+	// A crash mid-write leaves a truncated line
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"time":"2024-01-01T12:00:05Z","ident` + "\n")
+	file.Close()
+
+	entries, err := l.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Query() returned %d entries, want 2", len(entries))
+	}
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request headers used by HMAC-signed requests. The signature itself is sent
+// as "Authorization: HMAC-SHA256 <hex signature>".
+const (
+	HMACScheme      = "HMAC-SHA256"
+	HeaderKeyID     = "X-Agent-Key-Id"
+	HeaderTimestamp = "X-Agent-Timestamp"
+	HeaderNonce     = "X-Agent-Nonce"
+
+	defaultKeyID = "default"
+	maxNonceLen  = 128
+)
+
+var (
+	ErrMissingHeaders = errors.New("missing signature headers")
+	ErrUnknownKey     = errors.New("unknown key id")
+	ErrBadTimestamp   = errors.New("invalid timestamp")
+	ErrClockSkew      = errors.New("timestamp outside allowed clock skew")
+	ErrBadNonce       = errors.New("invalid nonce")
+	ErrReplay         = errors.New("nonce already used")
+	ErrBadSignature   = errors.New("signature mismatch")
+)
+
+// HMACVerifier checks HMAC-SHA256 request signatures over the method, path,
+// timestamp and nonce of a request. A request is only accepted once: its
+// nonce is remembered for the whole skew window.
+type HMACVerifier struct {
+	keys    map[string][]byte
+	maxSkew time.Duration
+	nonces  *NonceCache
+	now     func() time.Time
+}
+
+// NewHMACVerifier creates a verifier for the given key id -> secret map.
+// Requests without an explicit key id are checked against the "default" key.
+func NewHMACVerifier(keys map[string]string, maxSkew time.Duration, nonceCacheSize int) *HMACVerifier {
+	v := &HMACVerifier{
+		keys:    make(map[string][]byte, len(keys)),
+		maxSkew: maxSkew,
+		nonces:  NewNonceCache(nonceCacheSize),
+		now:     time.Now,
+	}
+	for id, secret := range keys {
+		v.keys[id] = []byte(secret)
+	}
+	return v
+}
+
+// Verify validates the signature on r and returns the key id that signed it.
+// signature is the value following the HMAC-SHA256 scheme in the
+// Authorization header.
+func (v *HMACVerifier) Verify(r *http.Request, signature string) (string, error) {
+	keyID := r.Header.Get(HeaderKeyID)
+	if keyID == "" {
+		keyID = defaultKeyID
+	}
+	tsHeader := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	if signature == "" || tsHeader == "" || nonce == "" {
+		return "", ErrMissingHeaders
+	}
+
+	secret, ok := v.keys[keyID]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+
+	ts, err := strconv.ParseInt(tsHeader, 10, 64)
+	if err != nil {
+		return "", ErrBadTimestamp
+	}
+	requestTime := time.Unix(ts, 0)
+	now := v.now()
+	if requestTime.Before(now.Add(-v.maxSkew)) || requestTime.After(now.Add(v.maxSkew)) {
+		return "", ErrClockSkew
+	}
+
+	if len(nonce) > maxNonceLen || strings.ContainsAny(nonce, "\n\r") {
+		return "", ErrBadNonce
+	}
+
+	provided, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return "", ErrBadSignature
+	}
+	expected := Sign(secret, r.Method, r.URL.RequestURI(), tsHeader, nonce)
+	if !hmac.Equal(provided, expected) {
+		return "", ErrBadSignature
+	}
+
+	// Only remember nonces of correctly signed requests so that unauthenticated
+	// clients can't flush the cache.
+	if !v.nonces.Add(keyID+":"+nonce, requestTime, now.Add(-v.maxSkew)) {
+		return "", ErrReplay
+	}
+
+	return keyID, nil
+}
+
+// Sign computes the HMAC-SHA256 signature for a request. The signed string is
+// the method, request URI (path and query), timestamp and nonce joined by
+// newlines.
+func Sign(secret []byte, method, requestURI, timestamp, nonce string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.ToUpper(method) + "\n" + requestURI + "\n" + timestamp + "\n" + nonce))
+	return mac.Sum(nil)
+}
+
+// NonceCache remembers recently seen nonces up to a fixed capacity.
+//
+// When the cache is full the oldest entry is evicted and its timestamp becomes
+// the new floor: any request signed before the floor is rejected, since we
+// can no longer prove its nonce is fresh. Timestamps have one-second
+// resolution, so the nonces evicted at the floor itself are kept and only
+// those are rejected at it; other requests signed in the same second still
+// get through. This keeps memory bounded without opening a replay window.
+type NonceCache struct {
+	mu        sync.Mutex
+	capacity  int
+	order     *list.List
+	entries   map[string]*list.Element
+	floor     time.Time
+	floorKeys map[string]bool // Nonces evicted with timestamp == floor
+}
+
+type nonceEntry struct {
+	key       string
+	timestamp time.Time
+}
+
+// NewNonceCache creates a cache holding at most capacity nonces.
+func NewNonceCache(capacity int) *NonceCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &NonceCache{
+		capacity:  capacity,
+		order:     list.New(),
+		entries:   make(map[string]*list.Element),
+		floorKeys: make(map[string]bool),
+	}
+}
+
+// Add records a nonce seen with the given request timestamp. It returns false
+// if the nonce was already used, the timestamp is below the eviction floor,
+// or it is at the floor and the nonce was evicted there. Entries with
+// timestamps before expireBefore are dropped first.
+func (n *NonceCache) Add(key string, timestamp, expireBefore time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// Expire entries that are outside the skew window; they can't be replayed
+	// because their timestamps would be rejected anyway.
+	for e := n.order.Front(); e != nil; {
+		entry := e.Value.(*nonceEntry)
+		if !entry.timestamp.Before(expireBefore) {
+			break
+		}
+		next := e.Next()
+		n.order.Remove(e)
+		delete(n.entries, entry.key)
+		e = next
+	}
+	if len(n.floorKeys) > 0 && n.floor.Before(expireBefore) {
+		n.floorKeys = make(map[string]bool)
+	}
+
+	if _, ok := n.entries[key]; ok {
+		return false
+	}
+
+	if !n.floor.IsZero() && (timestamp.Before(n.floor) || timestamp.Equal(n.floor) && n.floorKeys[key]) {
+		return false
+	}
+	// Making room by evicting a newer entry would put the floor above
+	// timestamp, so reject without evicting anything
+	if n.order.Len() >= n.capacity && timestamp.Before(n.order.Front().Value.(*nonceEntry).timestamp) {
+		return false
+	}
+
+	for n.order.Len() >= n.capacity {
+		oldest := n.order.Front()
+		entry := oldest.Value.(*nonceEntry)
+		n.order.Remove(oldest)
+		delete(n.entries, entry.key)
+		if entry.timestamp.After(n.floor) {
+			n.floor = entry.timestamp
+			n.floorKeys = make(map[string]bool)
+		}
+		n.floorKeys[entry.key] = true
+	}
+
+	// Keep the list ordered by timestamp so expiry and eviction stay cheap.
+	entry := &nonceEntry{key: key, timestamp: timestamp}
+	e := n.order.Back()
+	for e != nil && e.Value.(*nonceEntry).timestamp.After(timestamp) {
+		e = e.Prev()
+	}
+	if e == nil {
+		n.entries[key] = n.order.PushFront(entry)
+	} else {
+		n.entries[key] = n.order.InsertAfter(entry, e)
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"encoding/hex"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNonceCacheAdd(t *testing.T) {
+	type add struct {
+		key    string
+		ts     int64 // Request timestamp, unix seconds
+		expire int64 // expireBefore, unix seconds
+		want   bool
+	}
+	tests := []struct {
+		name     string
+		capacity int
+		adds     []add
+	}{
+		{
+			name:     "fresh nonces",
+			capacity: 4,
+			adds: []add{
+				{key: "a", ts: 100, want: true},
+				{key: "b", ts: 100, want: true},
+				{key: "c", ts: 101, want: true},
+			},
+		},
+		{
+			name:     "replay",
+			capacity: 4,
+			adds: []add{
+				{key: "a", ts: 100, want: true},
+				{key: "a", ts: 100, want: false},
+				{key: "a", ts: 101, want: false},
+			},
+		},
+		{
+			name:     "evicted nonce replayed at floor",
+			capacity: 1,
+			adds: []add{
+				{key: "a", ts: 100, want: true},
+				{key: "b", ts: 101, want: true},
+				{key: "a", ts: 100, want: false},
+			},
+		},
+		{
+			name:     "new nonce in the evicted second",
+			capacity: 2,
+			adds: []add{
+				{key: "a", ts: 100, want: true},
+				{key: "b", ts: 100, want: true},
+				{key: "c", ts: 100, want: true},
+				{key: "d", ts: 100, want: true},
+				{key: "a", ts: 100, want: false},
+				{key: "b", ts: 100, want: false},
+			},
+		},
+		{
+			name:     "below floor",
+			capacity: 1,
+			adds: []add{
+				{key: "a", ts: 100, want: true},
+				{key: "b", ts: 101, want: true},
+				{key: "c", ts: 99, want: false},
+			},
+		},
+		{
+			name:     "floor moves up",
+			capacity: 1,
+			adds: []add{
+				{key: "a", ts: 100, want: true},
+				{key: "b", ts: 101, want: true},
+				{key: "c", ts: 102, want: true},
+				{key: "d", ts: 100, want: false},
+				{key: "b", ts: 101, want: false},
+				{key: "e", ts: 101, want: false},
+				{key: "f", ts: 102, want: true},
+				{key: "c", ts: 102, want: false},
+			},
+		},
+		{
+			name:     "expired entries make room without a floor",
+			capacity: 2,
+			adds: []add{
+				{key: "a", ts: 100, want: true},
+				{key: "b", ts: 200, expire: 150, want: true},
+				{key: "c", ts: 160, expire: 150, want: true},
+			},
+		},
+		{
+			name:     "out of order timestamps",
+			capacity: 2,
+			adds: []add{
+				{key: "a", ts: 105, want: true},
+				{key: "b", ts: 100, want: true},
+				{key: "c", ts: 106, want: true},
+				{key: "d", ts: 101, want: false},
+				{key: "a", ts: 105, want: false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewNonceCache(tt.capacity)
+			for i, a := range tt.adds {
+				got := cache.Add(a.key, time.Unix(a.ts, 0), time.Unix(a.expire, 0))
+				if got != a.want {
+					t.Errorf("add #%d (%s at %d) = %v, want %v", i+1, a.key, a.ts, got, a.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHMACVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	secret := "s3cret"
+	tests := []struct {
+		name    string
+		keyID   string
+		ts      int64
+		nonce   string
+		secret  string
+		path    string // Path signed, if not the one requested
+		replay  bool
+		wantErr error
+	}{
+		{name: "valid", ts: now.Unix(), nonce: "n1"},
+		{name: "named key", keyID: "mac", ts: now.Unix(), nonce: "n1"},
+		{name: "unknown key", keyID: "other", ts: now.Unix(), nonce: "n1", wantErr: ErrUnknownKey},
+		{name: "missing nonce", ts: now.Unix(), wantErr: ErrMissingHeaders},
+		{name: "too old", ts: now.Unix() - 301, nonce: "n1", wantErr: ErrClockSkew},
+		{name: "too new", ts: now.Unix() + 301, nonce: "n1", wantErr: ErrClockSkew},
+		{name: "wrong secret", ts: now.Unix(), nonce: "n1", secret: "other", wantErr: ErrBadSignature},
+		{name: "other path", ts: now.Unix(), nonce: "n1", path: "/v1/errors", wantErr: ErrBadSignature},
+		{name: "replay", ts: now.Unix(), nonce: "n1", replay: true, wantErr: ErrReplay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewHMACVerifier(map[string]string{"default": secret, "mac": secret}, 300*time.Second, 16)
+			v.now = func() time.Time { return now }
+
+			signWith, path := secret, "/v1/stats"
+			if tt.secret != "" {
+				signWith = tt.secret
+			}
+			if tt.path != "" {
+				path = tt.path
+			}
+			ts := strconv.FormatInt(tt.ts, 10)
+			signature := hex.EncodeToString(Sign([]byte(signWith), "GET", path, ts, tt.nonce))
+
+			r := httptest.NewRequest("GET", "/v1/stats", nil)
+			if tt.keyID != "" {
+				r.Header.Set(HeaderKeyID, tt.keyID)
+			}
+			r.Header.Set(HeaderTimestamp, ts)
+			if tt.nonce != "" {
+				r.Header.Set(HeaderNonce, tt.nonce)
+			}
+
+			if tt.replay {
+				if _, err := v.Verify(r, signature); err != nil {
+					t.Fatalf("first request: %v", err)
+				}
+			}
+			_, err := v.Verify(r, signature)
+			if err != tt.wantErr {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
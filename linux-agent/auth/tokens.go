@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Scopes understood by the agent.
+const (
+	ScopeRead  = "read"
+	ScopeAdmin = "admin"
+)
+
+// AnonymousIdentity is reported for requests when no authentication is
+// configured.
+const AnonymousIdentity = "anonymous"
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Identity is an authenticated caller.
+type Identity struct {
+	Name   string
+	Scopes []string
+	// Redaction names the redaction policy applied to everything this
+	// identity reads. Empty means no redaction.
+	Redaction string
+}
+
+// HasScope reports whether the identity was granted scope. The admin scope
+// implies every other scope.
+func (i *Identity) HasScope(scope string) bool {
+	if i == nil {
+		return false
+	}
+	for _, s := range i.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenConfig describes one entry of the tokens file. An entry may carry a
+// bearer token, an HMAC secret (used with its name as the key id), or both.
+type TokenConfig struct {
+	Name       string   `json:"name"`
+	Token      string   `json:"token,omitempty"`
+	HMACSecret string   `json:"hmacSecret,omitempty"`
+	Scopes     []string `json:"scopes,omitempty"`
+	Redaction  string   `json:"redaction,omitempty"`
+}
+
+// TokensFile is the content of AGENT_TOKENS_FILE.
+type TokensFile struct {
+	Tokens []TokenConfig `json:"tokens"`
+	// RedactionPolicies maps a policy name to field -> action ("drop" or
+	// "hash"). Tokens refer to a policy by name.
+	RedactionPolicies map[string]map[string]string `json:"redactionPolicies,omitempty"`
+}
+
+// LoadTokensFile reads token definitions from a JSON file of the form
+// {"tokens": [{"name": "mac", "token": "...", "scopes": ["read"]}]}.
+// Entries without scopes default to read-only.
+func LoadTokensFile(path string) (*TokensFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file TokensFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range file.Tokens {
+		t := &file.Tokens[i]
+		if t.Name == "" {
+			return nil, fmt.Errorf("token #%d has no name", i+1)
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("duplicate token name %q", t.Name)
+		}
+		seen[t.Name] = true
+		if t.Token == "" && t.HMACSecret == "" {
+			return nil, fmt.Errorf("token %q has neither token nor hmacSecret", t.Name)
+		}
+		if len(t.Scopes) == 0 {
+			t.Scopes = []string{ScopeRead}
+		}
+		for _, s := range t.Scopes {
+			if s != ScopeRead && s != ScopeAdmin {
+				return nil, fmt.Errorf("token %q has unknown scope %q", t.Name, s)
+			}
+		}
+	}
+	return &file, nil
+}
+
+type bearerEntry struct {
+	token    []byte
+	identity *Identity
+}
+
+// Authenticator resolves the identity behind a request using bearer tokens
+// or HMAC signatures.
+type Authenticator struct {
+	bearers    []bearerEntry
+	hmac       *HMACVerifier
+	identities map[string]*Identity
+}
+
+// NewAuthenticator builds an authenticator from token definitions. HMAC
+// signed requests use the entry's name as key id.
+func NewAuthenticator(tokens []TokenConfig, maxSkew time.Duration, nonceCacheSize int) *Authenticator {
+	a := &Authenticator{identities: make(map[string]*Identity)}
+	hmacKeys := make(map[string]string)
+
+	for _, t := range tokens {
+		identity := &Identity{Name: t.Name, Scopes: t.Scopes, Redaction: t.Redaction}
+		a.identities[t.Name] = identity
+		if t.Token != "" {
+			a.bearers = append(a.bearers, bearerEntry{token: []byte(t.Token), identity: identity})
+		}
+		if t.HMACSecret != "" {
+			hmacKeys[t.Name] = t.HMACSecret
+		}
+	}
+
+	if len(hmacKeys) > 0 {
+		a.hmac = NewHMACVerifier(hmacKeys, maxSkew, nonceCacheSize)
+	}
+	return a
+}
+
+// Enabled reports whether any credentials are configured. When disabled,
+// every request is served as the anonymous identity.
+func (a *Authenticator) Enabled() bool {
+	return len(a.bearers) > 0 || a.hmac != nil
+}
+
+// HMACEnabled reports whether signed requests are accepted.
+func (a *Authenticator) HMACEnabled() bool {
+	return a.hmac != nil
+}
+
+// Authenticate checks the Authorization header of r and returns the caller.
+func (a *Authenticator) Authenticate(r *http.Request) (*Identity, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, ErrUnauthorized
+	}
+
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 {
+		return nil, ErrUnauthorized
+	}
+
+	switch parts[0] {
+	case "Bearer":
+		// Compare against every token so timing doesn't reveal which one matched.
+		var match *Identity
+		for _, b := range a.bearers {
+			if subtle.ConstantTimeCompare([]byte(parts[1]), b.token) == 1 {
+				match = b.identity
+			}
+		}
+		if match == nil {
+			return nil, ErrUnauthorized
+		}
+		return match, nil
+	case HMACScheme:
+		if a.hmac == nil {
+			return nil, ErrUnauthorized
+		}
+		keyID, err := a.hmac.Verify(r, parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return a.identities[keyID], nil
+	default:
+		return nil, ErrUnauthorized
+	}
+}
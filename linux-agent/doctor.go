@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/olivertemple/menubar_stats/linux-agent/stats"
+)
+
+var procPIDPath = regexp.MustCompile(`/proc/[0-9]+(/|$)`)
+
+// capabilityHint explains which Linux capability would restore access to a
+// path that failed an access check.
+func capabilityHint(check stats.AccessCheck) string {
+	if !check.PermissionDenied {
+		return ""
+	}
+	if procPIDPath.MatchString(check.Path) {
+		return "CAP_SYS_PTRACE (reading other users' process details)"
+	}
+	return "CAP_DAC_READ_SEARCH (bypasses file read permission checks)"
+}
+
+// runDoctor prints which collectors can read their data sources, before and
+// after dropping privileges, and what would restore anything that is
+// missing. It returns the process exit code.
+func runDoctor() int {
+	privileges, err := loadPrivilegeConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	opts, err := loadCollectorOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+	c := stats.NewCollector(opts)
+
+	fmt.Printf("MenuBarStats Linux Agent v%s doctor\n", agentVersion)
+	fmt.Printf("running as uid %d gid %d\n\n", os.Geteuid(), os.Getegid())
+
+	before := c.CheckAccess()
+	printAccessChecks(before)
+
+	if !privileges.enabled {
+		fmt.Println("\nAGENT_UID is not set; the agent keeps running as the current user.")
+		return exitCodeFor(before)
+	}
+
+	if err := dropPrivileges(privileges); err != nil {
+		fmt.Fprintf(os.Stderr, "\nerror: failed to drop privileges: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\nafter dropping to uid %d gid %d:\n\n", privileges.uid, privileges.gid)
+	after := c.CheckAccess()
+	printAccessChecks(after)
+
+	lost := stats.LostAccess(before, after)
+	if len(lost) == 0 {
+		fmt.Println("\nno collector lost access by dropping privileges.")
+	} else {
+		fmt.Println("\ncollectors that lost access after dropping privileges:")
+		for _, check := range lost {
+			fmt.Printf("  %-10s %s\n", check.Collector, check.Path)
+			if hint := capabilityHint(check); hint != "" {
+				fmt.Printf("             restored by %s\n", hint)
+			}
+		}
+		fmt.Println("\nCapabilities are cleared when switching to a non-root uid. To keep these")
+		fmt.Println("collectors, leave AGENT_UID unset and run the container with only the")
+		fmt.Println("capabilities above (e.g. --cap-drop ALL --cap-add DAC_READ_SEARCH) instead of")
+		fmt.Println("--privileged, or relax the permissions on the listed files.")
+	}
+
+	if auditPath := os.Getenv("AGENT_AUDIT_LOG"); auditPath != "" {
+		dir := filepath.Dir(auditPath)
+		probe, err := os.CreateTemp(dir, ".doctor-*")
+		if err != nil {
+			fmt.Printf("\naudit log directory %s is not writable after dropping privileges: %v\n", dir, err)
+			fmt.Println("rotation will fail; chown it to the agent uid.")
+		} else {
+			probe.Close()
+			os.Remove(probe.Name())
+		}
+	}
+
+	return exitCodeFor(after)
+}
+
+func printAccessChecks(checks []stats.AccessCheck) {
+	for _, check := range checks {
+		status := "ok"
+		switch {
+		case check.OK:
+		case check.PermissionDenied:
+			status = "denied"
+		case check.Optional:
+			status = "missing"
+		default:
+			status = "FAIL"
+		}
+		line := fmt.Sprintf("  %-8s %-10s %s", status, check.Collector, check.Path)
+		if check.Error != "" {
+			line += " (" + strings.TrimPrefix(check.Error, "open "+check.Path+": ") + ")"
+		}
+		fmt.Println(line)
+		if hint := capabilityHint(check); hint != "" {
+			fmt.Printf("           %-10s needs %s\n", "", hint)
+		}
+	}
+}
+
+// exitCodeFor returns 1 if any required path is unreadable.
+func exitCodeFor(checks []stats.AccessCheck) int {
+	for _, check := range checks {
+		if !check.OK && !check.Optional {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/olivertemple/menubar_stats/linux-agent/stats"
+)
+
+func TestCapabilityHint(t *testing.T) {
+	tests := []struct {
+		name  string
+		check stats.AccessCheck
+		want  string
+	}{
+		{name: "ok", check: stats.AccessCheck{Path: "/proc/1/io", OK: true}, want: ""},
+		{name: "missing", check: stats.AccessCheck{Path: "/proc/1/io", Error: "no such file"}, want: ""},
+		{name: "process io", check: stats.AccessCheck{Path: "/proc/1/io", PermissionDenied: true}, want: "CAP_SYS_PTRACE"},
+		{name: "host process cmdline", check: stats.AccessCheck{Path: "/host/proc/1/cmdline", PermissionDenied: true}, want: "CAP_SYS_PTRACE"},
+		{name: "process table", check: stats.AccessCheck{Path: "/proc", PermissionDenied: true}, want: "CAP_DAC_READ_SEARCH"},
+		{name: "proc file", check: stats.AccessCheck{Path: "/proc/stat", PermissionDenied: true}, want: "CAP_DAC_READ_SEARCH"},
+		{name: "sensor", check: stats.AccessCheck{Path: "/sys/class/hwmon/hwmon2/temp1_input", PermissionDenied: true}, want: "CAP_DAC_READ_SEARCH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := capabilityHint(tt.check)
+			if tt.want == "" && got != "" || !strings.HasPrefix(got, tt.want) {
+				t.Errorf("capabilityHint(%+v) = %q, want %q", tt.check, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCapabilityHintForProcessChecks checks that CheckAccess probes per-PID
+// files, so a lost process detail gets the ptrace hint. Tests may run as
+// root, so the denial itself is simulated.
+func TestCapabilityHintForProcessChecks(t *testing.T) {
+	if _, err := os.Stat("/proc/1"); err != nil {
+		t.Skip("no /proc on this host")
+	}
+	c := stats.NewCollector(stats.Options{})
+
+	found := map[string]bool{}
+	for _, check := range c.CheckAccess() {
+		if check.Collector != "processes" {
+			continue
+		}
+		check.PermissionDenied = true
+		name := filepath.Base(check.Path)
+		if name == "io" || name == "cmdline" {
+			found[name] = true
+			if hint := capabilityHint(check); !strings.HasPrefix(hint, "CAP_SYS_PTRACE") {
+				t.Errorf("%s: hint = %q, want CAP_SYS_PTRACE", check.Path, hint)
+			}
+		}
+	}
+	if !found["io"] || !found["cmdline"] {
+		t.Errorf("CheckAccess() has no per-PID process checks: %v", found)
+	}
+}
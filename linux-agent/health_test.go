@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/olivertemple/menubar_stats/linux-agent/auth"
+	"github.com/olivertemple/menubar_stats/linux-agent/stats"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	authenticator = auth.NewAuthenticator(nil, time.Minute, 16)
+
+	tests := []struct {
+		name       string
+		start      bool
+		block      bool // The source never finishes its first run
+		wantStatus string
+		wantHealth int
+		wantReady  int
+	}{
+		{name: "sampler not started", wantStatus: stats.HealthFailed, wantHealth: http.StatusServiceUnavailable, wantReady: http.StatusServiceUnavailable},
+		{name: "first sample pending", start: true, block: true, wantStatus: stats.HealthStarting, wantHealth: http.StatusOK, wantReady: http.StatusServiceUnavailable},
+		{name: "serving data", start: true, wantStatus: stats.HealthOK, wantHealth: http.StatusOK, wantReady: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			collector = stats.NewCollector(stats.Options{Interval: time.Second, SourceTimeout: time.Minute})
+			registry := collector.Registry()
+			for _, source := range registry.Sources() {
+				registry.SetEnabled(source.Name(), false)
+			}
+			ran := make(chan struct{})
+			registry.Register(stats.NewSource("test", time.Second, func(sctx context.Context, s *stats.RemoteLinuxStats) {
+				if tt.block {
+					<-ctx.Done()
+				}
+				s.Tasks = &stats.TaskStats{Available: true}
+				close(ran)
+			}))
+			if tt.start {
+				collector.Start(ctx)
+			}
+			if tt.start && !tt.block {
+				<-ran
+				deadline := time.Now().Add(time.Second)
+				for collector.Health().Status != stats.HealthOK && time.Now().Before(deadline) {
+					time.Sleep(time.Millisecond)
+				}
+			}
+
+			for _, endpoint := range []struct {
+				path    string
+				handler http.HandlerFunc
+				want    int
+			}{
+				{"/v1/health", handleHealth, tt.wantHealth},
+				{"/v1/health/ready", handleReady, tt.wantReady},
+			} {
+				rec := httptest.NewRecorder()
+				endpoint.handler(rec, httptest.NewRequest(http.MethodGet, endpoint.path, nil))
+				if rec.Code != endpoint.want {
+					t.Errorf("%s: status %d, want %d", endpoint.path, rec.Code, endpoint.want)
+				}
+				var body HealthResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatalf("%s: %v", endpoint.path, err)
+				}
+				if body.Status != tt.wantStatus {
+					t.Errorf("%s: health %s, want %s", endpoint.path, body.Status, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
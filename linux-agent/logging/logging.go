@@ -0,0 +1,199 @@
+// Package logging configures the agent's structured logger: a text or JSON
+// slog handler with a global level and per-component overrides that can be
+// changed while the agent runs.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ComponentKey is the attribute that names the component a logger belongs
+// to. Per-component levels are matched against it.
+const ComponentKey = "component"
+
+// ParseLevel parses debug, info, warn (or warning) and error.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (valid: debug, info, warn, error)", s)
+}
+
+// LevelName returns the lower-case name ParseLevel accepts.
+func LevelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// Levels holds the default level and per-component overrides. It is safe
+// for concurrent use.
+type Levels struct {
+	mu         sync.RWMutex
+	level      slog.Level
+	components map[string]slog.Level
+}
+
+// ParseLevels parses a level spec: a default level optionally followed by
+// component=level overrides, e.g. "info,stats=debug,http=warn".
+func ParseLevels(spec string) (*Levels, error) {
+	levels := &Levels{level: slog.LevelInfo, components: make(map[string]slog.Level)}
+	for i, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		component, value, isOverride := strings.Cut(part, "=")
+		if !isOverride {
+			if i != 0 {
+				return nil, fmt.Errorf("default level %q must come first", part)
+			}
+			value = component
+		}
+		level, err := ParseLevel(value)
+		if err != nil {
+			return nil, err
+		}
+		if isOverride {
+			levels.components[strings.TrimSpace(component)] = level
+		} else {
+			levels.level = level
+		}
+	}
+	return levels, nil
+}
+
+// For returns the level that applies to component.
+func (l *Levels) For(component string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if level, ok := l.components[component]; ok {
+		return level
+	}
+	return l.level
+}
+
+// Default returns the level of components without an override.
+func (l *Levels) Default() slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.level
+}
+
+// SetDefault changes the level of components without an override.
+func (l *Levels) SetDefault(level slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+// SetComponent overrides the level of one component.
+func (l *Levels) SetComponent(component string, level slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.components[component] = level
+}
+
+// ClearComponent removes a component's override.
+func (l *Levels) ClearComponent(component string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.components, component)
+}
+
+// Components returns the per-component overrides by name.
+func (l *Levels) Components() map[string]string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	components := make(map[string]string, len(l.components))
+	for component, level := range l.components {
+		components[component] = LevelName(level)
+	}
+	return components
+}
+
+// String formats the levels the way ParseLevels accepts them.
+func (l *Levels) String() string {
+	parts := []string{LevelName(l.Default())}
+	components := l.Components()
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, name+"="+components[name])
+	}
+	return strings.Join(parts, ",")
+}
+
+// Setup installs a text or JSON handler writing to w as the default slog
+// logger, which the standard log package also writes through. Source
+// locations are included when the default level is debug.
+func Setup(w io.Writer, format string, levels *Levels) error {
+	opts := &slog.HandlerOptions{
+		Level:     slog.LevelDebug, // Filtering happens in handler
+		AddSource: levels.Default() == slog.LevelDebug,
+	}
+
+	var inner slog.Handler
+	switch format {
+	case "text":
+		inner = slog.NewTextHandler(w, opts)
+	case "json":
+		inner = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q (valid: text, json)", format)
+	}
+
+	slog.SetDefault(slog.New(&handler{inner: inner, levels: levels}))
+	return nil
+}
+
+// For returns a logger for component. Call it after Setup; loggers created
+// earlier keep the handler that was the default at the time.
+func For(component string) *slog.Logger {
+	return slog.Default().With(ComponentKey, component)
+}
+
+// handler filters records by the level of the logger's component.
+type handler struct {
+	inner     slog.Handler
+	levels    *Levels
+	component string
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.levels.For(h.component)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	component := h.component
+	for _, attr := range attrs {
+		if attr.Key == ComponentKey {
+			component = attr.Value.String()
+		}
+	}
+	return &handler{inner: h.inner.WithAttrs(attrs), levels: h.levels, component: component}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{inner: h.inner.WithGroup(name), levels: h.levels, component: h.component}
+}
@@ -1,84 +1,289 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/olivertemple/menubar_stats/linux-agent/audit"
+	"github.com/olivertemple/menubar_stats/linux-agent/auth"
+	"github.com/olivertemple/menubar_stats/linux-agent/logging"
 	"github.com/olivertemple/menubar_stats/linux-agent/stats"
 )
 
 const (
-	defaultPort       = "9955"
-	defaultInterval   = "1000"
-	defaultLogLevel   = "info"
-	agentVersion      = "1.0.0"
+	defaultPort         = "9955"
+	defaultInterval     = "1000"
+	defaultLogLevel     = "info"
+	defaultLogFormat    = "text"
+	defaultHMACSkew     = "300"
+	defaultNonceCache   = "10000"
+	defaultAuditSize    = "10"
+	defaultAuditFiles   = "5"
+	defaultTimeout      = "3000"
+	defaultIdleAfter    = "300"
+	defaultIdleRate     = "60000"
+	defaultIRQImbalance = "80"
+	defaultProcessLimit = 20
+	agentVersion        = "1.0.0"
 )
 
 var (
-	collector   *stats.Collector
-	bearerToken string
+	collector         *stats.Collector
+	authenticator     *auth.Authenticator
+	auditLog          *audit.Logger
+	redactionPolicies map[string]*stats.RedactionPolicy
+	logLevels         *logging.Levels
+	agentLog          = logging.For("agent")
+	httpLog           = logging.For("http")
 )
 
+// HealthResponse keeps the original health fields for older clients and
+// adds the detailed state. OK is false only when the agent has failed.
+// Hostname is left out when authentication is enabled.
 type HealthResponse struct {
 	OK           bool   `json:"ok"`
 	Schema       string `json:"schema"`
 	AgentVersion string `json:"agent_version"`
-	Hostname     string `json:"hostname"`
+	Hostname     string `json:"hostname,omitempty"`
+	stats.Health
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(runDoctor())
+	}
+
+	// Logging comes first so configuration errors are logged in the
+	// configured format
+	logLevel := getEnv("AGENT_LOG_LEVEL", defaultLogLevel)
+	logFormat := getEnv("AGENT_LOG_FORMAT", defaultLogFormat)
+	var err error
+	logLevels, err = logging.ParseLevels(logLevel)
+	if err != nil {
+		fatal("invalid AGENT_LOG_LEVEL", "error", err)
+	}
+	if err := logging.Setup(os.Stderr, logFormat, logLevels); err != nil {
+		fatal("invalid AGENT_LOG_FORMAT", "error", err)
+	}
+	agentLog = logging.For("agent")
+	httpLog = logging.For("http")
+
 	// Configuration
 	port := getEnv("AGENT_PORT", defaultPort)
-	intervalMs := getEnv("AGENT_INTERVAL_MS", defaultInterval)
-	bearerToken = os.Getenv("AGENT_TOKEN")
-	logLevel := getEnv("AGENT_LOG_LEVEL", defaultLogLevel)
+	bearerToken := os.Getenv("AGENT_TOKEN")
+	tokensFile := os.Getenv("AGENT_TOKENS_FILE")
+	hmacSecret := os.Getenv("AGENT_HMAC_SECRET")
+	hmacSkew := getEnv("AGENT_HMAC_MAX_SKEW_SEC", defaultHMACSkew)
+	nonceCache := getEnv("AGENT_HMAC_NONCE_CACHE", defaultNonceCache)
+	auditPath := os.Getenv("AGENT_AUDIT_LOG")
+	auditSizeMB := getEnv("AGENT_AUDIT_MAX_SIZE_MB", defaultAuditSize)
+	auditFiles := getEnv("AGENT_AUDIT_MAX_FILES", defaultAuditFiles)
+	sandboxMode := getEnv("AGENT_SANDBOX", "off")
+	redactionKey := os.Getenv("AGENT_REDACTION_KEY")
+	disabledCollectors := os.Getenv("AGENT_DISABLED_COLLECTORS")
+	collectorIntervals := os.Getenv("AGENT_COLLECTOR_INTERVALS")
 
-	// Parse interval
-	intervalMsInt, err := strconv.Atoi(intervalMs)
-	if err != nil || intervalMsInt < 100 {
-		log.Fatalf("error: invalid AGENT_INTERVAL_MS: %s (must be >= 100)", intervalMs)
+	collectorOptions, err := loadCollectorOptions()
+	if err != nil {
+		fatal("invalid collector configuration", "error", err)
 	}
-	interval := time.Duration(intervalMsInt) * time.Millisecond
 
-	// Configure logging
-	if logLevel != "debug" {
-		log.SetFlags(log.LstdFlags)
-	} else {
-		log.SetFlags(log.LstdFlags | log.Lshortfile)
+	privileges, err := loadPrivilegeConfig()
+	if err != nil {
+		fatal("invalid privilege configuration", "error", err)
+	}
+
+	if sandboxMode != "off" && sandboxMode != "landlock" {
+		fatal("invalid AGENT_SANDBOX", "value", sandboxMode, "want", "off or landlock")
 	}
 
-	log.Printf("info: starting MenuBarStats Linux Agent v%s", agentVersion)
-	log.Printf("info: config - port: %s, interval: %dms, auth: %v", port, intervalMsInt, bearerToken != "")
+	// Credentials: AGENT_TOKEN / AGENT_HMAC_SECRET define the "default"
+	// identity with full access, AGENT_TOKENS_FILE adds named identities.
+	var tokens []auth.TokenConfig
+	if bearerToken != "" || hmacSecret != "" {
+		tokens = append(tokens, auth.TokenConfig{
+			Name:       "default",
+			Token:      bearerToken,
+			HMACSecret: hmacSecret,
+			Scopes:     []string{auth.ScopeRead, auth.ScopeAdmin},
+		})
+	}
+	var policyConfig map[string]map[string]string
+	if tokensFile != "" {
+		file, err := auth.LoadTokensFile(tokensFile)
+		if err != nil {
+			fatal("invalid AGENT_TOKENS_FILE", "error", err)
+		}
+		tokens = append(tokens, file.Tokens...)
+		policyConfig = file.RedactionPolicies
+	}
+
+	// Redaction policies referenced by tokens
+	redactionPolicies, err = loadRedactionPolicies(policyConfig, redactionKey)
+	if err != nil {
+		fatal("invalid redaction policy", "error", err)
+	}
+	for _, t := range tokens {
+		if t.Redaction != "" && redactionPolicies[t.Redaction] == nil {
+			fatal("token uses unknown redaction policy", "token", t.Name, "policy", t.Redaction)
+		}
+	}
+
+	// HMAC request signing (alternative to bearer tokens for plain HTTP)
+	skewSec, err := strconv.Atoi(hmacSkew)
+	if err != nil || skewSec < 1 {
+		fatal("invalid AGENT_HMAC_MAX_SKEW_SEC", "value", hmacSkew, "want", ">= 1")
+	}
+	nonceCacheSize, err := strconv.Atoi(nonceCache)
+	if err != nil || nonceCacheSize < 1 {
+		fatal("invalid AGENT_HMAC_NONCE_CACHE", "value", nonceCache, "want", ">= 1")
+	}
+	authenticator = auth.NewAuthenticator(tokens, time.Duration(skewSec)*time.Second, nonceCacheSize)
+
+	// Audit log
+	if auditPath != "" {
+		sizeMB, err := strconv.Atoi(auditSizeMB)
+		if err != nil || sizeMB < 1 {
+			fatal("invalid AGENT_AUDIT_MAX_SIZE_MB", "value", auditSizeMB, "want", ">= 1")
+		}
+		files, err := strconv.Atoi(auditFiles)
+		if err != nil || files < 0 {
+			fatal("invalid AGENT_AUDIT_MAX_FILES", "value", auditFiles, "want", ">= 0")
+		}
+		auditLog, err = audit.NewLogger(auditPath, int64(sizeMB)*1024*1024, files)
+		if err != nil {
+			fatal("failed to open audit log", "error", err)
+		}
+		defer auditLog.Close()
+	}
+
+	agentLog.Info("starting MenuBarStats Linux Agent", "version", agentVersion)
+	agentLog.Info("config", "port", port, "intervalMs", collectorOptions.Interval.Milliseconds(), "auth", authenticator.Enabled(),
+		"hmac", authenticator.HMACEnabled(), "identities", len(tokens), "audit", auditLog != nil, "logLevel", logLevels.String())
 
 	// Initialize collector
-	collector = stats.NewCollector(interval)
+	collector = stats.NewCollector(collectorOptions)
+	for _, name := range strings.Split(disabledCollectors, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if err := collector.Registry().SetEnabled(name, false); err != nil {
+			fatal("invalid AGENT_DISABLED_COLLECTORS", "error", err)
+		}
+		agentLog.Info("collector disabled", "collector", name)
+	}
+	for _, pair := range strings.Split(collectorIntervals, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			fatal("invalid AGENT_COLLECTOR_INTERVALS entry", "entry", pair, "want", "name=duration")
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d < 100*time.Millisecond {
+			fatal("invalid AGENT_COLLECTOR_INTERVALS entry", "entry", pair, "want", "duration >= 100ms")
+		}
+		if err := collector.Registry().SetInterval(strings.TrimSpace(name), d); err != nil {
+			fatal("invalid AGENT_COLLECTOR_INTERVALS", "error", err)
+		}
+	}
 
 	// Setup HTTP server
 	mux := http.NewServeMux()
 	mux.HandleFunc("/v1/health", handleHealth)
-	mux.HandleFunc("/v1/stats", authMiddleware(handleStats))
+	mux.HandleFunc("/v1/health/ready", handleReady)
+	mux.HandleFunc("/v1/stats", authMiddleware(auth.ScopeRead, handleStats))
+	mux.HandleFunc("/v1/capabilities", authMiddleware(auth.ScopeRead, handleCapabilities))
+	mux.HandleFunc("/v1/errors", authMiddleware(auth.ScopeRead, handleErrors))
+	mux.HandleFunc("/v1/processes", authMiddleware(auth.ScopeRead, handleProcesses))
+	mux.HandleFunc("/v1/admin/audit", authMiddleware(auth.ScopeAdmin, handleAudit))
+	mux.HandleFunc("/v1/admin/log-level", authMiddleware(auth.ScopeAdmin, handleLogLevel))
+	mux.HandleFunc("/debug/pprof/", authMiddleware(auth.ScopeAdmin, pprofTimeout(pprof.Index)))
+	mux.HandleFunc("/debug/pprof/cmdline", authMiddleware(auth.ScopeAdmin, pprofTimeout(pprof.Cmdline)))
+	mux.HandleFunc("/debug/pprof/profile", authMiddleware(auth.ScopeAdmin, pprofTimeout(pprof.Profile)))
+	mux.HandleFunc("/debug/pprof/symbol", authMiddleware(auth.ScopeAdmin, pprofTimeout(pprof.Symbol)))
+	mux.HandleFunc("/debug/pprof/trace", authMiddleware(auth.ScopeAdmin, pprofTimeout(pprof.Trace)))
 
 	server := &http.Server{
 		Addr:         ":" + port,
-		Handler:      mux,
+		Handler:      auditMiddleware(timingMiddleware(mux)),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// Open the listener before dropping privileges so low ports still work
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		fatal("failed to listen", "port", port, "error", err)
+	}
+
+	// Drop privileges now that everything needing root is open
+	if privileges.enabled {
+		before := collector.CheckAccess()
+		if err := dropPrivileges(privileges); err != nil {
+			fatal("failed to drop privileges", "error", err)
+		}
+		agentLog.Info("dropped privileges", "uid", privileges.uid, "gid", privileges.gid)
+
+		lost := stats.LostAccess(before, collector.CheckAccess())
+		for _, check := range lost {
+			agentLog.Warn("collector lost access after dropping privileges",
+				"collector", check.Collector, "path", check.Path, "hint", "run '"+os.Args[0]+" doctor' for details")
+		}
+	}
+
+	// Restrict filesystem access to what the collectors need
+	if sandboxMode == "landlock" {
+		var configFiles, writeDirs []string
+		if tokensFile != "" {
+			configFiles = append(configFiles, tokensFile)
+		}
+		if auditPath != "" {
+			writeDirs = append(writeDirs, filepath.Dir(auditPath))
+		}
+		var sources []string
+		for _, source := range collector.Registry().Enabled() {
+			sources = append(sources, source.Name())
+		}
+		policy := buildSandboxPolicy(collector.DataPaths(), sources, configFiles, writeDirs)
+		if err := applySandbox(policy); errors.Is(err, errSandboxUnavailable) {
+			logging.For("sandbox").Warn("sandbox not applied, continuing without it", "reason", err)
+		} else if err != nil {
+			fatal("failed to apply sandbox", "error", err)
+		}
+	}
+
+	// Probe and start sampling now that the process runs with its final
+	// permissions
+	collector.Probe(context.Background())
+	samplerCtx, stopSampler := context.WithCancel(context.Background())
+	collector.Start(samplerCtx)
+
 	// Start server
 	go func() {
-		log.Printf("info: listening on :%s", port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("error: server failed: %v", err)
+		agentLog.Info("listening", "port", port)
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			fatal("server failed", "error", err)
 		}
 	}()
 
@@ -87,38 +292,58 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("info: shutting down server...")
+	agentLog.Info("shutting down server")
+	stopSampler()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("error: server shutdown failed: %v", err)
+		agentLog.Error("server shutdown failed", "error", err)
 	}
 
-	log.Println("info: server stopped")
+	agentLog.Info("server stopped")
 }
 
+// handleHealth is the liveness check. It answers 200 while the sampler is
+// running, even if collectors are failing, so the body can be inspected;
+// use /v1/health/ready to check that the agent is producing data.
 func handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, r, func(health stats.Health) bool { return health.Sampler.Running })
+}
+
+// handleReady answers 200 once the agent serves usable data: it is ok or
+// degraded, not starting or failed.
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, r, func(health stats.Health) bool {
+		return health.Status == stats.HealthOK || health.Status == stats.HealthDegraded
+	})
+}
+
+func writeHealth(w http.ResponseWriter, r *http.Request, healthy func(stats.Health) bool) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	hostname, _ := os.Hostname()
+	health := collector.Health()
 	response := HealthResponse{
-		OK:           true,
+		OK:           health.Status != stats.HealthFailed,
 		Schema:       "v1",
 		AgentVersion: agentVersion,
-		Hostname:     hostname,
+		Health:       health,
+	}
+	// Health needs no credentials, so it only names the host when nothing
+	// else does either
+	if !authenticator.Enabled() {
+		response.Hostname, _ = os.Hostname()
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("error: failed to encode health response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	status := http.StatusOK
+	if !healthy(health) {
+		status = http.StatusServiceUnavailable
 	}
+	writeJSON(w, r, status, response)
 }
 
 func handleStats(w http.ResponseWriter, r *http.Request) {
@@ -127,46 +352,282 @@ func handleStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stats := collector.Collect()
+	// Serving stats counts as client activity, keeping the sampler at
+	// full rate
+	collector.Touch("http")
+	start := time.Now()
+	stats, err := redactFor(r, collector.Collect())
+	if err != nil {
+		httpLog.Error("failed to redact stats", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	snapshot := time.Since(start)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-	
-	encoder := json.NewEncoder(w)
+	// Encode into a buffer so the encoding time can go in Server-Timing
+	start = time.Now()
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
 	encoder.SetIndent("", "  ")
 	if err := encoder.Encode(stats); err != nil {
-		log.Printf("error: failed to encode stats: %v", err)
+		httpLog.Error("failed to encode stats", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	encode := time.Since(start)
+
+	w.Header().Set("Server-Timing", serverTiming(snapshot, encode))
+	writeBody(w, http.StatusOK, body.Bytes())
 }
 
-func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if bearerToken == "" {
-			next(w, r)
-			return
+// serverTiming formats the Server-Timing header for /v1/stats: the time to
+// build and encode the snapshot, then the latest run of each collector,
+// slowest first.
+func serverTiming(snapshot, encode time.Duration) string {
+	metrics := []string{
+		fmt.Sprintf("snapshot;dur=%.3f", durationMs(snapshot)),
+		fmt.Sprintf("encode;dur=%.3f", durationMs(encode)),
+	}
+
+	durations := collector.LastDurations()
+	names := make([]string, 0, len(durations))
+	for name := range durations {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if durations[names[i]] != durations[names[j]] {
+			return durations[names[i]] > durations[names[j]]
 		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		metrics = append(metrics, fmt.Sprintf("%s;dur=%.3f;desc=\"last %s collection\"", name, durationMs(durations[name]), name))
+	}
+	return strings.Join(metrics, ", ")
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000.0
+}
+
+// CapabilitiesResponse lists the collectors the agent knows about, whether
+// each works on this host and which optional features are present.
+type CapabilitiesResponse struct {
+	Schema string `json:"schema"`
+	stats.CapabilityReport
+}
+
+// handleCapabilities serves the capability probe taken at startup, or a
+// fresh one with ?refresh=1.
+func handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var report stats.CapabilityReport
+	if refresh, _ := strconv.ParseBool(r.URL.Query().Get("refresh")); refresh {
+		report = collector.Probe(r.Context())
+	} else {
+		report = collector.Capabilities()
+	}
+	writeJSON(w, r, http.StatusOK, CapabilitiesResponse{Schema: "v1", CapabilityReport: report})
+}
+
+// ErrorsResponse lists active and recently recovered collection errors.
+type ErrorsResponse struct {
+	Schema string `json:"schema"`
+	stats.ErrorReport
+}
+
+func handleErrors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	writeJSON(w, r, http.StatusOK, ErrorsResponse{Schema: "v1", ErrorReport: collector.Errors()})
+}
+
+// ProcessesResponse is the top of the latest process sample.
+type ProcessesResponse struct {
+	Schema string `json:"schema"`
+	stats.ProcessList
+}
+
+// handleProcesses returns the top processes by ?sort= (cpu, memory, io,
+// read, write; default cpu), at most ?limit= of them (default 20).
+func handleProcesses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultProcessLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > stats.MaxProcessLimit {
+			http.Error(w, fmt.Sprintf("invalid limit: want 1 to %d", stats.MaxProcessLimit), http.StatusBadRequest)
 			return
 		}
+		limit = n
+	}
 
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
+	collector.Touch("http")
+	list, err := collector.Processes(r.URL.Query().Get("sort"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, ProcessesResponse{Schema: "v1", ProcessList: list})
+}
+
+// loadRedactionPolicies builds the named policies from the tokens file plus
+// the built-in "guest" policy. Hashes are keyed with AGENT_REDACTION_KEY so
+// they stay stable across restarts; without it a random key is used.
+func loadRedactionPolicies(config map[string]map[string]string, key string) (map[string]*stats.RedactionPolicy, error) {
+	hashKey := []byte(key)
+	if key == "" {
+		hashKey = make([]byte, 32)
+		if _, err := rand.Read(hashKey); err != nil {
+			return nil, err
 		}
+	}
 
-		if parts[1] != bearerToken {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	guest, err := stats.NewRedactionPolicy(stats.GuestRedaction, hashKey)
+	if err != nil {
+		return nil, err
+	}
+	policies := map[string]*stats.RedactionPolicy{"guest": guest}
+
+	for name, fields := range config {
+		actions := make(map[string]stats.RedactionAction, len(fields))
+		for field, action := range fields {
+			actions[field] = stats.RedactionAction(action)
+		}
+		policy, err := stats.NewRedactionPolicy(actions, hashKey)
+		if err != nil {
+			return nil, fmt.Errorf("policy %q: %w", name, err)
+		}
+		policies[name] = policy
+	}
+	return policies, nil
+}
+
+// handleAudit serves audit entries, newest first. Supports since/until
+// (RFC 3339 or unix seconds), identity and limit query parameters.
+func handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if auditLog == nil {
+		http.Error(w, "Audit log not enabled", http.StatusNotFound)
+		return
+	}
+
+	params := r.URL.Query()
+	query := audit.Query{
+		Identity: params.Get("identity"),
+		Limit:    100,
+	}
+
+	var err error
+	if v := params.Get("since"); v != "" {
+		if query.Since, err = parseTimeParam(v); err != nil {
+			http.Error(w, "Invalid since parameter", http.StatusBadRequest)
 			return
 		}
+	}
+	if v := params.Get("until"); v != "" {
+		if query.Until, err = parseTimeParam(v); err != nil {
+			http.Error(w, "Invalid until parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 10000 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		query.Limit = limit
+	}
+
+	entries, err := auditLog.Query(query)
+	if err != nil {
+		httpLog.Error("failed to query audit log", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+
+	writeJSON(w, r, http.StatusOK, entries)
+}
+
+func parseTimeParam(value string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
 
-		next(w, r)
+// LogLevelResponse is the current default level and component overrides.
+type LogLevelResponse struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+// LogLevelRequest changes the default level, or a component's level when
+// Component is set. An empty Level clears the component's override.
+type LogLevelRequest struct {
+	Component string `json:"component,omitempty"`
+	Level     string `json:"level"`
+}
+
+// handleLogLevel reports the log levels on GET and changes them on PUT.
+func handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req LogLevelRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Component != "" && req.Level == "" {
+			logLevels.ClearComponent(req.Component)
+		} else {
+			level, err := logging.ParseLevel(req.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if req.Component != "" {
+				logLevels.SetComponent(req.Component, level)
+			} else {
+				logLevels.SetDefault(level)
+			}
+		}
+		identity := ""
+		if id := identityFrom(r); id != nil {
+			identity = id.Name
+		}
+		agentLog.Info("log level changed", "levels", logLevels.String(), "identity", identity)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
+
+	writeJSON(w, r, http.StatusOK, LogLevelResponse{Level: logging.LevelName(logLevels.Default()), Components: logLevels.Components()})
+}
+
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Default().With(logging.ComponentKey, "agent").Error(msg, args...)
+	os.Exit(1)
 }
 
 func getEnv(key, defaultValue string) string {
@@ -175,3 +636,76 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// loadCollectorOptions reads the collector settings from the environment.
+// The server and the doctor command share it, so the doctor checks the
+// same cgroups and pidfiles the server will read.
+func loadCollectorOptions() (stats.Options, error) {
+	var opts stats.Options
+
+	intervalMs := getEnv("AGENT_INTERVAL_MS", defaultInterval)
+	ms, err := strconv.Atoi(intervalMs)
+	if err != nil || ms < 100 {
+		return opts, fmt.Errorf("invalid AGENT_INTERVAL_MS: %s (must be >= 100)", intervalMs)
+	}
+	opts.Interval = time.Duration(ms) * time.Millisecond
+
+	timeoutMs := getEnv("AGENT_COLLECTOR_TIMEOUT_MS", defaultTimeout)
+	ms, err = strconv.Atoi(timeoutMs)
+	if err != nil || ms < 100 {
+		return opts, fmt.Errorf("invalid AGENT_COLLECTOR_TIMEOUT_MS: %s (must be >= 100)", timeoutMs)
+	}
+	opts.SourceTimeout = time.Duration(ms) * time.Millisecond
+
+	idleAfterSec := getEnv("AGENT_IDLE_AFTER_SEC", defaultIdleAfter)
+	sec, err := strconv.Atoi(idleAfterSec)
+	if err != nil || sec < 0 {
+		return opts, fmt.Errorf("invalid AGENT_IDLE_AFTER_SEC: %s (must be >= 0, 0 disables idle mode)", idleAfterSec)
+	}
+	opts.IdleAfter = time.Duration(sec) * time.Second
+
+	idleIntervalMs := getEnv("AGENT_IDLE_INTERVAL_MS", defaultIdleRate)
+	ms, err = strconv.Atoi(idleIntervalMs)
+	if err != nil || ms < 100 {
+		return opts, fmt.Errorf("invalid AGENT_IDLE_INTERVAL_MS: %s (must be >= 100)", idleIntervalMs)
+	}
+	opts.IdleInterval = time.Duration(ms) * time.Millisecond
+
+	// Cgroups to report PSI for, as paths in the cgroup v2 hierarchy
+	for _, cg := range strings.Split(os.Getenv("AGENT_PSI_CGROUPS"), ",") {
+		cg = strings.Trim(strings.TrimSpace(cg), "/")
+		if cg == "" {
+			continue
+		}
+		if cleaned := filepath.Clean(cg); cleaned != cg || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return opts, fmt.Errorf("invalid AGENT_PSI_CGROUPS entry: %s (must be a cgroup path such as system.slice/docker.service)", cg)
+		}
+		opts.PressureCgroups = append(opts.PressureCgroups, cg)
+	}
+
+	irqImbalance := getEnv("AGENT_IRQ_IMBALANCE_PERCENT", defaultIRQImbalance)
+	opts.IRQImbalancePercent, err = strconv.ParseFloat(irqImbalance, 64)
+	if err != nil || opts.IRQImbalancePercent <= 0 || opts.IRQImbalancePercent > 100 {
+		return opts, fmt.Errorf("invalid AGENT_IRQ_IMBALANCE_PERCENT: %s (must be > 0 and <= 100)", irqImbalance)
+	}
+
+	// Watchlist: process names from AGENT_WATCH, full entries from
+	// AGENT_WATCH_FILE
+	for _, name := range strings.Split(os.Getenv("AGENT_WATCH"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.Watchlist = append(opts.Watchlist, stats.WatchRule{Name: name, Process: name})
+		}
+	}
+	if watchFile := os.Getenv("AGENT_WATCH_FILE"); watchFile != "" {
+		rules, err := stats.LoadWatchFile(watchFile)
+		if err != nil {
+			return opts, fmt.Errorf("invalid AGENT_WATCH_FILE: %w", err)
+		}
+		opts.Watchlist = append(opts.Watchlist, rules...)
+	}
+	if err := stats.ValidateWatchRules(opts.Watchlist); err != nil {
+		return opts, fmt.Errorf("invalid watchlist: %w", err)
+	}
+
+	return opts, nil
+}
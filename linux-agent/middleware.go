@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/olivertemple/menubar_stats/linux-agent/audit"
+	"github.com/olivertemple/menubar_stats/linux-agent/auth"
+)
+
+type contextKey int
+
+const requestInfoKey contextKey = iota
+
+// requestInfo carries per-request state between middlewares. The audit
+// middleware creates it and the auth middleware fills in the identity.
+type requestInfo struct {
+	identity *auth.Identity
+}
+
+func requestInfoFrom(r *http.Request) *requestInfo {
+	if info, ok := r.Context().Value(requestInfoKey).(*requestInfo); ok {
+		return info
+	}
+	return nil
+}
+
+// identityFrom returns the caller identity set by authMiddleware, if any.
+func identityFrom(r *http.Request) *auth.Identity {
+	if info := requestInfoFrom(r); info != nil {
+		return info.identity
+	}
+	return nil
+}
+
+// responseRecorder captures the status code and body size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += int64(n)
+	return n, err
+}
+
+// Flush forwards to the wrapped writer so streamed responses aren't held
+// back.
+func (rr *responseRecorder) Flush() {
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+// auditMiddleware records every request in the audit log, including
+// rejected ones.
+func auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := &requestInfo{}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey, info))
+
+		if auditLog == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		identity := ""
+		if info.identity != nil {
+			identity = info.identity.Name
+		}
+		remote := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(remote); err == nil {
+			remote = host
+		}
+
+		entry := audit.Entry{
+			Time:       start.UTC(),
+			RemoteAddr: remote,
+			Identity:   identity,
+			Method:     r.Method,
+			Endpoint:   r.URL.Path,
+			Status:     status,
+			Bytes:      rec.bytes,
+			LatencyMs:  float64(time.Since(start).Microseconds()) / 1000.0,
+		}
+		if err := auditLog.Record(entry); err != nil {
+			httpLog.Error("failed to write audit log", "error", err)
+		}
+	})
+}
+
+// timingMiddleware records the latency of every request, keyed by the
+// route pattern that handled it so unknown paths don't add endpoints.
+func timingMiddleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		mux.ServeHTTP(w, r)
+
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		collector.RecordRequest(pattern, time.Since(start))
+	})
+}
+
+// pprofWriteTimeout replaces the server's write timeout for pprof, which
+// streams CPU profiles and traces for as long as they were asked to run.
+const pprofWriteTimeout = 2 * time.Minute
+
+// pprofServer carries pprofWriteTimeout to net/http/pprof, which checks
+// profile durations against the write timeout of the server in the request
+// context. It is never started.
+var pprofServer = &http.Server{WriteTimeout: pprofWriteTimeout}
+
+// pprofTimeout gives a pprof handler its own write timeout instead of the
+// server's.
+func pprofTimeout(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(pprofWriteTimeout)); err != nil {
+			httpLog.Warn("failed to extend pprof write deadline", "error", err)
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), http.ServerContextKey, pprofServer)))
+	}
+}
+
+// authMiddleware authenticates the request and checks that the caller holds
+// scope. Without configured credentials, read endpoints are open to the
+// anonymous identity but admin endpoints stay closed.
+func authMiddleware(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var identity *auth.Identity
+		if authenticator.Enabled() {
+			var err error
+			identity, err = authenticator.Authenticate(r)
+			if err != nil {
+				if err != auth.ErrUnauthorized {
+					httpLog.Warn("rejected request", "remoteAddr", r.RemoteAddr, "error", err)
+				}
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		} else {
+			identity = &auth.Identity{Name: auth.AnonymousIdentity, Scopes: []string{auth.ScopeRead}}
+		}
+
+		if info := requestInfoFrom(r); info != nil {
+			info.identity = identity
+		}
+
+		if !identity.HasScope(scope) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if identity.Redaction != "" {
+			w = &redactionGuard{ResponseWriter: w, endpoint: r.URL.Path}
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// privilegeConfig describes the unprivileged user the agent switches to once
+// startup is complete.
+type privilegeConfig struct {
+	enabled bool
+	uid     int
+	gid     int
+}
+
+// loadPrivilegeConfig reads AGENT_UID and AGENT_GID. AGENT_GID defaults to
+// the same value as AGENT_UID.
+func loadPrivilegeConfig() (privilegeConfig, error) {
+	uidStr := os.Getenv("AGENT_UID")
+	if uidStr == "" {
+		return privilegeConfig{}, nil
+	}
+
+	uid, err := strconv.Atoi(uidStr)
+	if err != nil || uid < 1 {
+		return privilegeConfig{}, fmt.Errorf("invalid AGENT_UID: %s (must be a non-root numeric uid)", uidStr)
+	}
+	gid := uid
+	if gidStr := os.Getenv("AGENT_GID"); gidStr != "" {
+		gid, err = strconv.Atoi(gidStr)
+		if err != nil || gid < 1 {
+			return privilegeConfig{}, fmt.Errorf("invalid AGENT_GID: %s (must be a non-root numeric gid)", gidStr)
+		}
+	}
+
+	return privilegeConfig{enabled: true, uid: uid, gid: gid}, nil
+}
+
+// dropPrivileges clears supplementary groups and switches to the configured
+// gid and uid. Since Go 1.16 these calls apply to every thread of the
+// process, so nothing keeps running as root afterwards.
+func dropPrivileges(cfg privilegeConfig) error {
+	if !cfg.enabled {
+		return nil
+	}
+
+	if os.Geteuid() != 0 {
+		if os.Geteuid() == cfg.uid && os.Getegid() == cfg.gid {
+			return nil // Already running as the target user
+		}
+		return fmt.Errorf("cannot switch to uid %d: not running as root", cfg.uid)
+	}
+
+	if err := syscall.Setgroups([]int{}); err != nil {
+		return fmt.Errorf("setgroups: %w", err)
+	}
+	if err := syscall.Setgid(cfg.gid); err != nil {
+		return fmt.Errorf("setgid %d: %w", cfg.gid, err)
+	}
+	if err := syscall.Setuid(cfg.uid); err != nil {
+		return fmt.Errorf("setuid %d: %w", cfg.uid, err)
+	}
+
+	// Make sure the switch can't be undone.
+	if err := syscall.Setuid(0); err == nil {
+		return fmt.Errorf("privileges were not dropped: setuid(0) succeeded")
+	}
+	if os.Getuid() != cfg.uid || os.Geteuid() != cfg.uid || os.Getgid() != cfg.gid || os.Getegid() != cfg.gid {
+		return fmt.Errorf("privileges were not dropped: running as uid %d gid %d", os.Geteuid(), os.Getegid())
+	}
+	return nil
+}
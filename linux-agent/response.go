@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/olivertemple/menubar_stats/linux-agent/audit"
+	"github.com/olivertemple/menubar_stats/linux-agent/stats"
+)
+
+// errUnredacted is returned for writes that didn't go through redaction.
+var errUnredacted = errors.New("response not redacted")
+
+// redactFor returns v as the caller may see it. Callers with a redaction
+// policy only get the response types listed here; any other type is an
+// error, so a new endpoint can't send collected data out unredacted.
+func redactFor(r *http.Request, v any) (any, error) {
+	identity := identityFrom(r)
+	if identity == nil || identity.Redaction == "" {
+		return v, nil
+	}
+	policy := redactionPolicies[identity.Redaction]
+
+	switch v := v.(type) {
+	case *stats.RemoteLinuxStats:
+		return policy.Apply(v), nil
+	case ErrorsResponse:
+		if !policy.Remembers() {
+			// Messages name values, such as the hostname, that are only
+			// known once a snapshot has been redacted; later snapshots
+			// are learned from as /v1/stats serves them
+			policy.Apply(collector.Collect())
+		}
+		v.ErrorReport = policy.ApplyErrors(v.ErrorReport)
+		return v, nil
+	case ProcessesResponse:
+		v.ProcessList = policy.ApplyProcesses(v.ProcessList)
+		return v, nil
+	case CapabilitiesResponse:
+		v.CapabilityReport = policy.ApplyCapabilities(v.CapabilityReport)
+		return v, nil
+	case []audit.Entry:
+		entries := make([]audit.Entry, len(v))
+		for i, entry := range v {
+			entry.RemoteAddr = policy.RedactText(entry.RemoteAddr)
+			entries[i] = entry
+		}
+		return entries, nil
+	case LogLevelResponse:
+		return v, nil
+	}
+	return nil, fmt.Errorf("no redaction for %T", v)
+}
+
+// writeJSON redacts v for the caller and writes it with status. Every
+// endpoint that returns data goes through here or redactFor.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	response, err := redactFor(r, v)
+	if err != nil {
+		httpLog.Error("failed to redact response", "endpoint", r.URL.Path, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(response); err != nil {
+		httpLog.Error("failed to encode response", "endpoint", r.URL.Path, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeBody(w, status, body.Bytes())
+}
+
+// writeBody writes a JSON body that has been through redactFor.
+func writeBody(w http.ResponseWriter, status int, body []byte) {
+	if guard, ok := w.(*redactionGuard); ok {
+		guard.redacted = true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	w.Write(body)
+}
+
+// redactionGuard wraps the response for callers with a redaction policy.
+// Only writeBody may send a successful body; any other write is refused
+// with 403, so a handler that skips redaction fails instead of leaking.
+// Error responses are plain text and pass through.
+type redactionGuard struct {
+	http.ResponseWriter
+	endpoint string
+	redacted bool
+	status   int
+	refused  bool
+}
+
+func (g *redactionGuard) WriteHeader(status int) {
+	if g.status == 0 {
+		g.status = status
+	}
+	if status < http.StatusBadRequest && !g.redacted {
+		return
+	}
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *redactionGuard) Write(b []byte) (int, error) {
+	if g.refused {
+		return 0, errUnredacted
+	}
+	if g.redacted || g.status >= http.StatusBadRequest {
+		return g.ResponseWriter.Write(b)
+	}
+	g.refused = true
+	httpLog.Warn("refused unredacted response", "endpoint", g.endpoint)
+	http.Error(g.ResponseWriter, "Not available with redaction", http.StatusForbidden)
+	return 0, errUnredacted
+}
+
+// Unwrap lets http.ResponseController reach the connection.
+func (g *redactionGuard) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/olivertemple/menubar_stats/linux-agent/auth"
+	"github.com/olivertemple/menubar_stats/linux-agent/stats"
+)
+
+func TestRedactionGuard(t *testing.T) {
+	guest, err := stats.NewRedactionPolicy(stats.GuestRedaction, []byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	redactionPolicies = map[string]*stats.RedactionPolicy{"guest": guest}
+	snapshot := &stats.RemoteLinuxStats{Schema: "v1", Hostname: "nas"}
+
+	tests := []struct {
+		name       string
+		redaction  string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+		notInBody  string
+	}{
+		{
+			name:      "writeJSON redacts",
+			redaction: "guest",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, r, http.StatusOK, snapshot)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"hostname":"h:`,
+			notInBody:  "nas",
+		},
+		{
+			name: "writeJSON without a policy",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, r, http.StatusOK, snapshot)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"hostname":"nas"`,
+		},
+		{
+			name:      "unknown response type",
+			redaction: "guest",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, r, http.StatusOK, map[string]string{"hostname": "nas"})
+			},
+			wantStatus: http.StatusInternalServerError,
+			notInBody:  "nas",
+		},
+		{
+			name:      "raw write",
+			redaction: "guest",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"hostname":"nas"}`))
+				w.Write([]byte(`{"hostname":"nas"}`))
+			},
+			wantStatus: http.StatusForbidden,
+			notInBody:  "nas",
+		},
+		{
+			name: "raw write without a policy",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("profile"))
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "profile",
+		},
+		{
+			name:      "error response",
+			redaction: "guest",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "invalid limit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity := &auth.Identity{Name: "test", Scopes: []string{auth.ScopeRead}, Redaction: tt.redaction}
+			info := &requestInfo{identity: identity}
+			r := httptest.NewRequest(http.MethodGet, "/v1/test", nil)
+			r = r.WithContext(context.WithValue(r.Context(), requestInfoKey, info))
+
+			var w http.ResponseWriter = httptest.NewRecorder()
+			rec := w.(*httptest.ResponseRecorder)
+			if tt.redaction != "" {
+				w = &redactionGuard{ResponseWriter: w, endpoint: r.URL.Path}
+			}
+			tt.handler(w, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			body := rec.Body.String()
+			if tt.wantBody != "" && !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", body, tt.wantBody)
+			}
+			if tt.notInBody != "" && strings.Contains(body, tt.notInBody) {
+				t.Errorf("body = %q, must not contain %q", body, tt.notInBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"debug/elf"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// sandboxPolicy lists the filesystem paths the agent may touch once the
+// sandbox is applied. Everything else becomes inaccessible, including other
+// host directories mounted into the container.
+type sandboxPolicy struct {
+	readOnly  []string // Files and directories the collectors read
+	readWrite []string // Directories the agent writes to (audit log)
+	exec      []string // Binaries the collectors run
+}
+
+// errSandboxUnavailable is returned by applySandbox when Landlock can't be
+// used; the agent then continues without the sandbox.
+var errSandboxUnavailable = errors.New("sandbox unavailable")
+
+// systemReadPaths are needed by the Go runtime rather than by a collector:
+// time zones for log and audit timestamps.
+var systemReadPaths = []string{
+	"/etc/localtime",
+	"/usr/share/zoneinfo",
+}
+
+// sourceSystemPaths are system files read on behalf of a source: DNS and
+// TLS for the external IP lookup, and user names for processes.
+var sourceSystemPaths = map[string][]string{
+	"external_ip": {
+		"/etc/resolv.conf",
+		"/etc/hosts",
+		"/etc/nsswitch.conf",
+		"/etc/ssl",
+		"/etc/pki",
+		"/etc/ca-certificates",
+		"/usr/share/ca-certificates",
+	},
+	"processes": {
+		"/etc/passwd",
+		"/etc/group",
+	},
+}
+
+// libraryDirs are searched for the shared libraries of exec'd tools, along
+// with the multiarch directories found beneath them.
+var libraryDirs = []string{
+	"/lib",
+	"/lib64",
+	"/usr/lib",
+	"/usr/lib64",
+}
+
+// buildSandboxPolicy collects the paths the agent needs: the enabled
+// collectors' data sources and system files, config and data files, and
+// the 'ip' binary when the network collector runs.
+func buildSandboxPolicy(dataPaths []string, sources []string, configFiles []string, writeDirs []string) sandboxPolicy {
+	policy := sandboxPolicy{
+		readOnly:  append(append([]string{}, dataPaths...), systemReadPaths...),
+		readWrite: append([]string{"/dev/null"}, writeDirs...),
+	}
+	policy.readOnly = append(policy.readOnly, configFiles...)
+
+	network := false
+	for _, name := range sources {
+		policy.readOnly = append(policy.readOnly, sourceSystemPaths[name]...)
+		network = network || name == "network"
+	}
+
+	if ipPath, err := exec.LookPath("ip"); err == nil && network {
+		policy.exec = append(policy.exec, ipPath)
+		// Busybox-style symlinks are resolved before the exec check.
+		if resolved, err := filepath.EvalSymlinks(ipPath); err == nil && resolved != ipPath {
+			policy.exec = append(policy.exec, resolved)
+		}
+		policy.exec = append(policy.exec, sharedLibraries(ipPath)...)
+		policy.readOnly = append(policy.readOnly, "/etc/ld.so.cache")
+	}
+
+	return policy
+}
+
+// sharedLibraries returns the dynamic loader and every shared library a
+// binary needs, directly or through other libraries. Static binaries need
+// none.
+func sharedLibraries(path string) []string {
+	dirs := append([]string{}, libraryDirs...)
+	for _, dir := range libraryDirs {
+		multiarch, _ := filepath.Glob(filepath.Join(dir, "*-linux-*"))
+		dirs = append(dirs, multiarch...)
+	}
+
+	var libs []string
+	seen := make(map[string]bool)
+	queue := []string{path}
+	for len(queue) > 0 {
+		f, err := elf.Open(queue[0])
+		queue = queue[1:]
+		if err != nil {
+			continue
+		}
+		for _, prog := range f.Progs {
+			if prog.Type != elf.PT_INTERP {
+				continue
+			}
+			if data, err := io.ReadAll(prog.Open()); err == nil {
+				if interp := strings.TrimRight(string(data), "\x00"); !seen[interp] {
+					seen[interp] = true
+					libs = append(libs, interp)
+				}
+			}
+		}
+		needed, _ := f.ImportedLibraries()
+		f.Close()
+
+		for _, name := range needed {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			for _, dir := range dirs {
+				candidate := filepath.Join(dir, name)
+				if _, err := os.Stat(candidate); err == nil {
+					libs = append(libs, candidate)
+					queue = append(queue, candidate)
+					break
+				}
+			}
+		}
+	}
+	return libs
+}
@@ -0,0 +1,177 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/olivertemple/menubar_stats/linux-agent/logging"
+)
+
+// Landlock ABI, see include/uapi/linux/landlock.h. The syscall numbers are
+// shared by every architecture the agent is built for.
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1 << 0
+	landlockRulePathBeneath      = 1
+
+	accessFsExecute    = 1 << 0
+	accessFsWriteFile  = 1 << 1
+	accessFsReadFile   = 1 << 2
+	accessFsReadDir    = 1 << 3
+	accessFsRemoveDir  = 1 << 4
+	accessFsRemoveFile = 1 << 5
+	accessFsMakeChar   = 1 << 6
+	accessFsMakeDir    = 1 << 7
+	accessFsMakeReg    = 1 << 8
+	accessFsMakeSock   = 1 << 9
+	accessFsMakeFifo   = 1 << 10
+	accessFsMakeBlock  = 1 << 11
+	accessFsMakeSym    = 1 << 12
+	accessFsRefer      = 1 << 13 // ABI 2
+	accessFsTruncate   = 1 << 14 // ABI 3
+	accessFsIoctlDev   = 1 << 15 // ABI 5
+
+	// Rights that may be granted on a regular file rather than a directory.
+	accessFsFileRights = accessFsExecute | accessFsWriteFile | accessFsReadFile | accessFsTruncate | accessFsIoctlDev
+
+	// Not defined by package syscall on every architecture.
+	oPath           = 0x200000
+	prSetNoNewPrivs = 38
+)
+
+type landlockRulesetAttr struct {
+	handledAccessFs uint64
+}
+
+// Matches the packed kernel struct: only the first 12 bytes are read.
+type landlockPathBeneathAttr struct {
+	allowedAccess uint64
+	parentFd      int32
+}
+
+// landlockABI returns the Landlock ABI version supported by the kernel, or
+// an error if Landlock is unavailable.
+func landlockABI() (int, error) {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(abi), nil
+}
+
+// handledAccess returns every filesystem right the given ABI knows about.
+// Handling a right without granting it denies it.
+func handledAccess(abi int) uint64 {
+	rights := uint64(accessFsExecute | accessFsWriteFile | accessFsReadFile | accessFsReadDir |
+		accessFsRemoveDir | accessFsRemoveFile | accessFsMakeChar | accessFsMakeDir |
+		accessFsMakeReg | accessFsMakeSock | accessFsMakeFifo | accessFsMakeBlock | accessFsMakeSym)
+	if abi >= 2 {
+		rights |= accessFsRefer
+	}
+	if abi >= 3 {
+		rights |= accessFsTruncate
+	}
+	if abi >= 5 {
+		rights |= accessFsIoctlDev
+	}
+	return rights
+}
+
+// applySandbox restricts the whole process to the paths in policy using
+// Landlock. On kernels without Landlock and in cgo builds it returns
+// errSandboxUnavailable, leaving the process unrestricted.
+func applySandbox(policy sandboxPolicy) error {
+	abi, err := landlockABI()
+	if err != nil {
+		if errors.Is(err, syscall.ENOSYS) || errors.Is(err, syscall.EOPNOTSUPP) {
+			return fmt.Errorf("%w: landlock is not supported by this kernel", errSandboxUnavailable)
+		}
+		return fmt.Errorf("landlock_create_ruleset: %w", err)
+	}
+
+	handled := handledAccess(abi)
+	attr := landlockRulesetAttr{handledAccessFs: handled}
+	fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock_create_ruleset: %w", errno)
+	}
+	rulesetFd := int(fd)
+	defer syscall.Close(rulesetFd)
+
+	read := uint64(accessFsReadFile | accessFsReadDir)
+	write := read | accessFsWriteFile | accessFsTruncate | accessFsMakeReg | accessFsRemoveFile
+	execute := read | accessFsExecute
+
+	added := 0
+	for _, rule := range []struct {
+		paths  []string
+		access uint64
+	}{
+		{policy.readOnly, read},
+		{policy.readWrite, write},
+		{policy.exec, execute},
+	} {
+		for _, path := range rule.paths {
+			ok, err := addPathRule(rulesetFd, path, rule.access&handled)
+			if err != nil {
+				return fmt.Errorf("landlock rule for %s: %w", path, err)
+			}
+			if ok {
+				added++
+			}
+		}
+	}
+
+	// Landlock requires no_new_privs, and both must apply to every thread
+	// of the Go runtime, not just the current one.
+	if _, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		if errno == syscall.ENOTSUP {
+			// AllThreadsSyscall is unavailable in cgo builds.
+			return fmt.Errorf("%w: landlock requires a CGO_ENABLED=0 build", errSandboxUnavailable)
+		}
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS): %w", errno)
+	}
+	if _, _, errno := syscall.AllThreadsSyscall(sysLandlockRestrictSelf, uintptr(rulesetFd), 0, 0); errno != 0 {
+		return fmt.Errorf("landlock_restrict_self: %w", errno)
+	}
+
+	logging.For("sandbox").Info("landlock sandbox applied", "abi", abi, "paths", added)
+	return nil
+}
+
+// addPathRule grants access beneath path. Paths that don't exist are
+// skipped and reported as not added.
+func addPathRule(rulesetFd int, path string, access uint64) (bool, error) {
+	fd, err := syscall.Open(path, oPath|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ENOTDIR) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer syscall.Close(fd)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if !info.IsDir() {
+		access &= accessFsFileRights
+	}
+
+	attr := landlockPathBeneathAttr{allowedAccess: access, parentFd: int32(fd)}
+	_, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(rulesetFd), landlockRulePathBeneath,
+		uintptr(unsafe.Pointer(&attr)), 0, 0, 0)
+	if errno != 0 {
+		return false, errno
+	}
+	return true, nil
+}
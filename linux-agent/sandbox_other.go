@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// applySandbox always returns errSandboxUnavailable outside Linux; Landlock
+// is Linux-only.
+func applySandbox(policy sandboxPolicy) error {
+	return fmt.Errorf("%w: landlock is only available on Linux", errSandboxUnavailable)
+}
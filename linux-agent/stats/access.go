@@ -0,0 +1,196 @@
+package stats
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// AccessCheck is the result of probing one path a collector reads.
+type AccessCheck struct {
+	Collector        string `json:"collector"`
+	Path             string `json:"path"`
+	OK               bool   `json:"ok"`
+	Optional         bool   `json:"optional,omitempty"`
+	PermissionDenied bool   `json:"permissionDenied,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+type accessTarget struct {
+	collector string
+	path      string
+	dir       bool
+	optional  bool
+}
+
+// DataPaths returns the files and directories the enabled sources read,
+// for the sandbox. Sources that walk the process table get all of procPath;
+// the others only their own files. sysfs class entries are symlinks into
+// the devices tree, so that comes with them. Daemons replace their pidfile
+// when they restart, so the directories of watched pidfiles are included
+// rather than the files. Sources registered outside the agent get procPath
+// and sysPath, as their reads are unknown.
+func (c *Collector) DataPaths() []string {
+	proc := func(name string) string { return filepath.Join(c.procPath, name) }
+	sys := func(name string) string { return filepath.Join(c.sysPath, name) }
+
+	// Rate counters check the boot ID to detect reboots
+	paths := []string{proc("sys/kernel/random/boot_id")}
+	for _, source := range c.registry.Enabled() {
+		switch source.Name() {
+		case "cpu":
+			paths = append(paths, proc("stat"), proc("loadavg"), proc("cpuinfo"), sys("devices/system/cpu"))
+		case "memory":
+			paths = append(paths, proc("meminfo"), proc("pressure/memory"), proc("spl/kstat/zfs/arcstats"))
+		case "disk":
+			paths = append(paths, proc("diskstats"))
+		case "filesystems":
+			// Container and TrueNAS detection read the real root
+			paths = append(paths, proc("mounts"), "/proc/1/cgroup", "/etc/os-release", "/etc/truenas-release")
+		case "network":
+			paths = append(paths, proc("net/dev"), sys("class/net"), sys("devices"))
+		case "external_ip", "gpu", "self":
+			// No files; see the sandbox for DNS and TLS
+		case "thermals":
+			paths = append(paths, sys("class/hwmon"), sys("devices"))
+		case "features":
+			paths = append(paths, proc("diskstats"), sys("class/hwmon"), sys("devices"))
+		case "pressure":
+			paths = append(paths, proc("pressure"))
+			root := c.cgroupRoot()
+			for _, path := range c.pressureCgroups {
+				paths = append(paths, filepath.Join(root, path))
+			}
+		case "interrupts":
+			paths = append(paths, proc("interrupts"), proc("softirqs"), sys("kernel/irq"), sys("class/net"), sys("devices"))
+		case "tasks", "processes":
+			paths = append(paths, c.procPath)
+		case "watch":
+			paths = append(paths, c.procPath)
+			for _, w := range c.watchers {
+				if w.rule.Pidfile != "" {
+					paths = append(paths, filepath.Dir(w.rule.Pidfile))
+				}
+			}
+		default:
+			paths = append(paths, c.procPath, c.sysPath)
+		}
+	}
+
+	sort.Strings(paths)
+	unique := paths[:0]
+	for i, path := range paths {
+		if i == 0 || path != paths[i-1] {
+			unique = append(unique, path)
+		}
+	}
+	return unique
+}
+
+// CheckAccess probes every path the collectors read and reports whether the
+// current process can read it. Running it before and after dropping
+// privileges shows which collectors lost access.
+func (c *Collector) CheckAccess() []AccessCheck {
+	targets := []accessTarget{
+		{collector: "cpu", path: filepath.Join(c.procPath, "stat")},
+		{collector: "cpu", path: filepath.Join(c.procPath, "loadavg")},
+		{collector: "cpu", path: filepath.Join(c.procPath, "cpuinfo")},
+		{collector: "cpu", path: filepath.Join(c.procPath, "sys/kernel/random/boot_id"), optional: true},
+		{collector: "cpu", path: filepath.Join(c.sysPath, "devices/system/cpu"), dir: true, optional: true},
+		{collector: "tasks", path: c.procPath, dir: true},
+		{collector: "processes", path: c.procPath, dir: true},
+		// I/O counters and command lines of other users' processes need
+		// ptrace access; PID 1 stands in for them.
+		{collector: "processes", path: filepath.Join(c.procPath, "1/io"), optional: true},
+		{collector: "processes", path: filepath.Join(c.procPath, "1/cmdline"), optional: true},
+		{collector: "interrupts", path: filepath.Join(c.procPath, "interrupts")},
+		{collector: "interrupts", path: filepath.Join(c.procPath, "softirqs"), optional: true},
+		{collector: "memory", path: filepath.Join(c.procPath, "meminfo")},
+		{collector: "memory", path: filepath.Join(c.procPath, "pressure/memory"), optional: true},
+		{collector: "memory", path: filepath.Join(c.procPath, "spl/kstat/zfs/arcstats"), optional: true},
+		{collector: "pressure", path: filepath.Join(c.procPath, "pressure/cpu"), optional: true},
+		{collector: "pressure", path: filepath.Join(c.procPath, "pressure/io"), optional: true},
+		{collector: "pressure", path: filepath.Join(c.procPath, "pressure/memory"), optional: true},
+		{collector: "disk", path: filepath.Join(c.procPath, "diskstats")},
+		{collector: "disk", path: filepath.Join(c.procPath, "mounts")},
+		{collector: "network", path: filepath.Join(c.procPath, "net/dev")},
+		{collector: "network", path: filepath.Join(c.sysPath, "class/net"), dir: true},
+		{collector: "thermals", path: filepath.Join(c.sysPath, "class/hwmon"), dir: true, optional: true},
+	}
+
+	if len(c.watchers) > 0 {
+		targets = append(targets, accessTarget{collector: "watch", path: c.procPath, dir: true})
+	}
+	for _, w := range c.watchers {
+		if w.rule.Pidfile != "" {
+			targets = append(targets, accessTarget{collector: "watch", path: w.rule.Pidfile, optional: true})
+		}
+	}
+
+	root := c.cgroupRoot()
+	for _, path := range c.pressureCgroups {
+		targets = append(targets, accessTarget{collector: "pressure", path: filepath.Join(root, path, "cpu.pressure")})
+	}
+
+	// Individual sensor files are frequently root-only, so check each one.
+	sensors, _ := filepath.Glob(filepath.Join(c.sysPath, "class/hwmon/hwmon*/temp*_input"))
+	sort.Strings(sensors)
+	for _, sensor := range sensors {
+		targets = append(targets, accessTarget{collector: "thermals", path: sensor, optional: true})
+	}
+
+	checks := make([]AccessCheck, 0, len(targets))
+	for _, t := range targets {
+		check := AccessCheck{Collector: t.collector, Path: t.path, Optional: t.optional}
+		var err error
+		if t.dir {
+			_, err = os.ReadDir(t.path)
+		} else {
+			err = probeRead(t.path)
+		}
+		if err != nil {
+			check.Error = err.Error()
+			check.PermissionDenied = errors.Is(err, fs.ErrPermission)
+		} else {
+			check.OK = true
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func probeRead(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Some sysfs attributes only fail on read, not on open.
+	buf := make([]byte, 1)
+	if _, err := file.Read(buf); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// LostAccess returns the checks that succeeded in before but fail in after.
+func LostAccess(before, after []AccessCheck) []AccessCheck {
+	ok := make(map[string]bool, len(before))
+	for _, check := range before {
+		if check.OK {
+			ok[check.Path] = true
+		}
+	}
+
+	var lost []AccessCheck
+	for _, check := range after {
+		if !check.OK && ok[check.Path] {
+			lost = append(lost, check)
+		}
+	}
+	return lost
+}
@@ -0,0 +1,68 @@
+package stats
+
+import "time"
+
+// Touch records that a consumer just read data. The agent's only consumers
+// today are the HTTP endpoints serving collected data, which report as
+// "http"; a stream or exporter added later should call Touch with its own
+// name. If the sampler was idle it is woken up and returns to full rate
+// immediately.
+func (c *Collector) Touch(consumer string) {
+	now := time.Now()
+
+	c.activityMu.Lock()
+	wasIdle := c.idle
+	c.lastActivity[consumer] = now
+	c.lastAnyActivity = now
+	c.idle = false
+	if wasIdle {
+		c.wokeAt = now
+	}
+	c.activityMu.Unlock()
+
+	if wasIdle {
+		c.log.Info("client activity resumed, switching back to full-rate sampling", "consumer", consumer)
+		select {
+		case c.wake <- struct{}{}:
+		default: // A wake-up is already pending
+		}
+	}
+}
+
+// Idle reports whether the sampler is running at the maintenance cadence
+// because no consumer has read data recently.
+func (c *Collector) Idle() bool {
+	c.activityMu.Lock()
+	defer c.activityMu.Unlock()
+	return c.idle
+}
+
+// LastActivity returns when each consumer last read data.
+func (c *Collector) LastActivity() map[string]time.Time {
+	c.activityMu.Lock()
+	defer c.activityMu.Unlock()
+
+	activity := make(map[string]time.Time, len(c.lastActivity))
+	for consumer, t := range c.lastActivity {
+		activity[consumer] = t
+	}
+	return activity
+}
+
+// updateIdle switches to idle once nobody has consumed data for idleAfter
+// and returns the current state.
+func (c *Collector) updateIdle(now time.Time) bool {
+	if c.idleAfter <= 0 {
+		return false
+	}
+
+	c.activityMu.Lock()
+	defer c.activityMu.Unlock()
+
+	if !c.idle && now.Sub(c.lastAnyActivity) >= c.idleAfter {
+		c.idle = true
+		c.log.Info("no client activity, sampling at the idle interval until the next request",
+			"idleAfter", c.idleAfter.String(), "interval", c.idleInterval.String())
+	}
+	return c.idle
+}
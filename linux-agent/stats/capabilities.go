@@ -0,0 +1,238 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Reasons a capability is unavailable.
+const (
+	ReasonMissingPath    = "missing_path"
+	ReasonPermission     = "permission_denied"
+	ReasonReadFailed     = "read_failed"
+	ReasonMissingTool    = "missing_tool"
+	ReasonNoDevices      = "no_devices"
+	ReasonNotImplemented = "not_implemented"
+	ReasonNotConfigured  = "not_configured"
+)
+
+// Capability says whether something works on this host and, if not, why.
+type Capability struct {
+	Available bool   `json:"available"`
+	Code      string `json:"code,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// CollectorCapability is a registered source together with its probe
+// result.
+type CollectorCapability struct {
+	SourceInfo
+	Capability
+}
+
+// CapabilityReport is the result of probing every collector and optional
+// feature. ProbedAt is in unix milliseconds.
+type CapabilityReport struct {
+	ProbedAt   int64                 `json:"probedAt"`
+	Collectors []CollectorCapability `json:"collectors"`
+	Features   map[string]Capability `json:"features"`
+}
+
+// Prober is implemented by sources that can check whether they will work
+// on this host without collecting. Sources without it count as available.
+type Prober interface {
+	Probe(ctx context.Context) Capability
+}
+
+// Feature names in CapabilityReport.Features, matching the Features section.
+const (
+	FeatureSmart   = "smart"
+	FeatureNvme    = "nvme"
+	FeatureThermal = "thermal"
+	FeatureGpu     = "gpu"
+)
+
+// gpuTools are the vendor tools GPU metrics would come from.
+var gpuTools = []string{"nvidia-smi", "rocm-smi", "intel_gpu_top"}
+
+// Probe checks every registered collector and feature, caches the result
+// for Capabilities and returns it. Enabled collectors are logged when they
+// are first found unavailable and when their availability changes.
+func (c *Collector) Probe(ctx context.Context) CapabilityReport {
+	c.capMu.Lock()
+	previous := make(map[string]Capability)
+	if c.capabilities != nil {
+		for _, cc := range c.capabilities.Collectors {
+			previous[cc.Name] = cc.Capability
+		}
+	}
+	c.capMu.Unlock()
+
+	probes := c.builtinProbes()
+	info := c.registry.Info()
+
+	report := CapabilityReport{
+		ProbedAt:   time.Now().UnixMilli(),
+		Collectors: make([]CollectorCapability, 0, len(info)),
+		Features:   c.probeFeatures(),
+	}
+	sources := make(map[string]Source)
+	for _, source := range c.registry.Sources() {
+		sources[source.Name()] = source
+	}
+
+	for _, si := range info {
+		capability := Capability{Available: true}
+		if probe, ok := probes[si.Name]; ok {
+			capability = probe()
+		} else if prober, ok := sources[si.Name].(Prober); ok {
+			capability = prober.Probe(ctx)
+		}
+		if prev, seen := previous[si.Name]; si.Enabled && (seen && prev != capability || !seen && !capability.Available) {
+			if capability.Available {
+				c.log.Info("collector available", "collector", si.Name)
+			} else {
+				c.log.Info("collector unavailable", "collector", si.Name, "code", capability.Code, "reason", capability.Reason)
+			}
+		}
+		report.Collectors = append(report.Collectors, CollectorCapability{SourceInfo: si, Capability: capability})
+	}
+
+	c.capMu.Lock()
+	c.capabilities = &report
+	c.capMu.Unlock()
+	return report
+}
+
+// Capabilities returns the last probe result, probing first if needed.
+// The features are refreshed every time the features collector runs.
+func (c *Collector) Capabilities() CapabilityReport {
+	c.capMu.Lock()
+	cached := c.capabilities
+	c.capMu.Unlock()
+
+	if cached == nil {
+		return c.Probe(context.Background())
+	}
+	return *cached
+}
+
+// builtinProbes returns the probes of the built-in collectors by name.
+func (c *Collector) builtinProbes() map[string]func() Capability {
+	proc := func(name string) string { return filepath.Join(c.procPath, name) }
+	return map[string]func() Capability{
+		"cpu":         func() Capability { return probePaths(proc("stat")) },
+		"memory":      func() Capability { return probePaths(proc("meminfo")) },
+		"disk":        func() Capability { return probePaths(proc("diskstats")) },
+		"filesystems": func() Capability { return probePaths(proc("mounts")) },
+		"network":     func() Capability { return probePaths(proc("net/dev")) },
+		"thermals":    c.probeThermals,
+		"gpu":         probeGPU,
+		"features":    func() Capability { return probePaths(proc("diskstats")) },
+		"tasks":       c.probeProcessTable,
+		"processes":   c.probeProcessTable,
+		"watch":       c.probeWatch,
+		"interrupts":  func() Capability { return probePaths(proc("interrupts")) },
+		"pressure": func() Capability {
+			return probePaths(proc("pressure/cpu"), proc("pressure/io"), proc("pressure/memory"))
+		},
+	}
+}
+
+// probeFeatures checks the optional hardware features the Mac shows panels
+// for.
+func (c *Collector) probeFeatures() map[string]Capability {
+	smart := probeSmart()
+
+	nvme := probePaths(filepath.Join(c.procPath, "diskstats"))
+	if nvme.Available {
+		data, _ := os.ReadFile(filepath.Join(c.procPath, "diskstats"))
+		if !strings.Contains(string(data), "nvme") {
+			nvme = Capability{Code: ReasonNoDevices, Reason: "no NVMe devices in diskstats"}
+		}
+	}
+
+	return map[string]Capability{
+		FeatureSmart:   smart,
+		FeatureNvme:    nvme,
+		FeatureThermal: c.probeThermals(),
+		FeatureGpu:     probeGPU(),
+	}
+}
+
+// probeSmart reports SMART as unavailable: there is no SMART collector yet.
+// The reason says whether smartctl is installed.
+func probeSmart() Capability {
+	if _, err := exec.LookPath("smartctl"); err == nil {
+		return Capability{Code: ReasonNotImplemented, Reason: "found smartctl, but SMART data is not collected yet"}
+	}
+	return Capability{Code: ReasonMissingTool, Reason: "smartctl not found in PATH"}
+}
+
+// probeThermals checks for hwmon temperature sensors and that the first one
+// is readable; sensor files are often root-only.
+func (c *Collector) probeThermals() Capability {
+	hwmon := filepath.Join(c.sysPath, "class/hwmon")
+	if _, err := os.ReadDir(hwmon); err != nil {
+		return unavailable(hwmon, err)
+	}
+	sensors, _ := filepath.Glob(filepath.Join(hwmon, "hwmon*/temp*_input"))
+	if len(sensors) == 0 {
+		return Capability{Code: ReasonNoDevices, Reason: "no temperature sensors under " + hwmon}
+	}
+	var lastErr error
+	for _, sensor := range sensors {
+		if lastErr = probeRead(sensor); lastErr == nil {
+			return Capability{Available: true}
+		}
+	}
+	return unavailable(sensors[len(sensors)-1], lastErr)
+}
+
+// probeProcessTable checks that the process table can be listed.
+func (c *Collector) probeProcessTable() Capability {
+	if _, err := c.listPIDs(); err != nil {
+		return unavailable(c.procPath, err)
+	}
+	return Capability{Available: true}
+}
+
+// probeGPU reports GPUs as unavailable: there is no GPU collector yet. The
+// reason says whether a vendor tool is installed.
+func probeGPU() Capability {
+	for _, tool := range gpuTools {
+		if _, err := exec.LookPath(tool); err == nil {
+			return Capability{Code: ReasonNotImplemented, Reason: fmt.Sprintf("found %s, but GPU metrics are not collected yet", tool)}
+		}
+	}
+	return Capability{Code: ReasonMissingTool, Reason: "no GPU vendor tool found (" + strings.Join(gpuTools, ", ") + ")"}
+}
+
+// probePaths checks that every path can be read.
+func probePaths(paths ...string) Capability {
+	for _, path := range paths {
+		if err := probeRead(path); err != nil {
+			return unavailable(path, err)
+		}
+	}
+	return Capability{Available: true}
+}
+
+// unavailable describes why path couldn't be read.
+func unavailable(path string, err error) Capability {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return Capability{Code: ReasonMissingPath, Reason: path + " does not exist"}
+	case errors.Is(err, fs.ErrPermission):
+		return Capability{Code: ReasonPermission, Reason: path + ": permission denied"}
+	default:
+		return Capability{Code: ReasonReadFailed, Reason: err.Error()}
+	}
+}
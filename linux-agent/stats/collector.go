@@ -1,948 +1,452 @@
 package stats
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"io"
-	"log"
-	"net"
-	"net/http"
+	"log/slog"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"strconv"
+	"reflect"
 	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
+
+	"github.com/olivertemple/menubar_stats/linux-agent/logging"
+)
+
+const (
+	// DefaultSourceTimeout bounds how long a single source may take before
+	// its section is left out of the snapshot.
+	DefaultSourceTimeout = 3 * time.Second
+
+	// statfsTimeout bounds a single statfs call; it is shorter than the
+	// source timeout so one stale mount doesn't cost the whole disk section.
+	statfsTimeout = time.Second
 )
 
 type Collector struct {
 	procPath     string
 	sysPath      string
 	interval     time.Duration
+	timeout      time.Duration
 	mu           sync.RWMutex
-	prevCPU      *cpuSnapshot
-	prevDisk     map[string]*diskSnapshot
-	prevNetwork  map[string]*networkSnapshot
-	errors       []string
-	loggedErrors map[string]bool
+	results      map[string]*sourceResult
+	cpuCounters  *counterTracker
+	diskCounters *counterTracker
+	netCounters  *counterTracker
+	// pressureCounters tracks PSI stall totals; pressureCgroups are the
+	// cgroup v2 paths, relative to the hierarchy root, to report PSI for.
+	pressureCounters *counterTracker
+	pressureCgroups  []string
+	// irqCounters tracks per-CPU interrupt counts; irqImbalancePercent is
+	// the share of a NIC's interrupts on one CPU that counts as imbalanced.
+	irqCounters         *counterTracker
+	irqImbalancePercent float64
+	// arcCounters tracks the ZFS ARC and L2ARC hit, miss and I/O counters.
+	arcCounters *counterTracker
+	// The process source's counters and caches are only used from its
+	// runs; procMu guards the latest sample served by Processes.
+	procCounters    *counterTracker
+	procIdentities  map[string]procIdentity
+	userNames       map[int]string
+	procMu          sync.Mutex
+	procList        []ProcessInfo
+	procTotal       int
+	procCollectedAt time.Time
+	// watchers are the watchlist entries and their state; watchCmdlines
+	// caches command lines for cmdline rules. Only used from watch runs.
+	watchers      []*watcher
+	watchCmdlines map[string]string
+	log           *slog.Logger
+	errors        *errorTracker
+	self          *selfMetrics
+	capMu         sync.Mutex
+	capabilities  *CapabilityReport
+	registry      *Registry
+	runningMu     sync.Mutex
+	running       map[string]bool
+	hungMu        sync.Mutex
+	hungMounts    map[string]bool
+
+	idleAfter       time.Duration
+	idleInterval    time.Duration
+	activityMu      sync.Mutex
+	lastActivity    map[string]time.Time
+	lastAnyActivity time.Time
+	idle            bool
+	wokeAt          time.Time
+	wake            chan struct{}
+
+	samplerStarted atomic.Int64 // Unix nanoseconds
+	samplerTick    atomic.Int64
 }
 
-type cpuSnapshot struct {
-	timestamp time.Time
-	total     uint64
-	idle      uint64
-	iowait    uint64
-	steal     uint64
+// Options configures a Collector.
+type Options struct {
+	// Interval is the default collection interval of the built-in sources.
+	Interval time.Duration
+	// SourceTimeout is the deadline for each source; DefaultSourceTimeout
+	// when zero.
+	SourceTimeout time.Duration
+	// IdleAfter is how long the sampler keeps full rate after the last
+	// consumer read; zero disables idle mode.
+	IdleAfter time.Duration
+	// IdleInterval is the maintenance cadence used while idle. Sources that
+	// are already slower keep their own interval.
+	IdleInterval time.Duration
+	// PressureCgroups are cgroup v2 paths, relative to the hierarchy root,
+	// whose PSI is reported alongside the host's.
+	PressureCgroups []string
+	// IRQImbalancePercent is the share of a NIC's interrupts one CPU may
+	// handle before it is flagged; DefaultIRQImbalancePercent when zero.
+	IRQImbalancePercent float64
+	// Watchlist are the processes the watch source checks for.
+	Watchlist []WatchRule
 }
 
-type diskSnapshot struct {
-	timestamp   time.Time
-	readBytes   uint64
-	writeBytes  uint64
-	readOps     uint64
-	writeOps    uint64
-}
-
-type networkSnapshot struct {
-	timestamp time.Time
-	rxBytes   uint64
-	txBytes   uint64
-}
+func NewCollector(opts Options) *Collector {
+	if opts.SourceTimeout <= 0 {
+		opts.SourceTimeout = DefaultSourceTimeout
+	}
+	if opts.IRQImbalancePercent <= 0 {
+		opts.IRQImbalancePercent = DefaultIRQImbalancePercent
+	}
 
-func NewCollector(interval time.Duration) *Collector {
+	logger := logging.For("stats")
 	c := &Collector{
-		procPath:     "/proc",
-		sysPath:      "/sys",
-		interval:     interval,
-		prevDisk:     make(map[string]*diskSnapshot),
-		prevNetwork:  make(map[string]*networkSnapshot),
-		loggedErrors: make(map[string]bool),
+		procPath:            "/proc",
+		sysPath:             "/sys",
+		interval:            opts.Interval,
+		timeout:             opts.SourceTimeout,
+		cpuCounters:         newCounterTracker(),
+		diskCounters:        newCounterTracker(),
+		netCounters:         newCounterTracker(),
+		pressureCounters:    newCounterTracker(),
+		pressureCgroups:     opts.PressureCgroups,
+		irqCounters:         newCounterTracker(),
+		arcCounters:         newCounterTracker(),
+		irqImbalancePercent: opts.IRQImbalancePercent,
+		procCounters:        newCounterTracker(),
+		procIdentities:      make(map[string]procIdentity),
+		userNames:           make(map[int]string),
+		results:             make(map[string]*sourceResult),
+		log:                 logger,
+		errors:              newErrorTracker(logger),
+		self:                newSelfMetrics(),
+		registry:            NewRegistry(),
+		running:             make(map[string]bool),
+		hungMounts:          make(map[string]bool),
+
+		idleAfter:       opts.IdleAfter,
+		idleInterval:    opts.IdleInterval,
+		lastActivity:    make(map[string]time.Time),
+		lastAnyActivity: time.Now(),
+		wake:            make(chan struct{}, 1),
+	}
+	if c.idleInterval < c.interval {
+		c.idleInterval = c.interval
 	}
 
 	// Auto-detect host mounts for TrueNAS SCALE
 	if _, err := os.Stat("/host/proc"); err == nil {
 		c.procPath = "/host/proc"
-		log.Printf("info: using /host/proc for TrueNAS SCALE compatibility")
+		c.log.Info("using /host/proc for TrueNAS SCALE compatibility")
 	}
 	if _, err := os.Stat("/host/sys"); err == nil {
 		c.sysPath = "/host/sys"
-		log.Printf("info: using /host/sys for TrueNAS SCALE compatibility")
-	}
-
-	log.Printf("info: monitoring paths - proc: %s, sys: %s", c.procPath, c.sysPath)
-	return c
-}
-
-func (c *Collector) Collect() *RemoteLinuxStats {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	c.errors = nil // Reset errors for this collection
-
-	hostname, _ := os.Hostname()
-	stats := &RemoteLinuxStats{
-		Schema:       "v1",
-		Timestamp:    time.Now().Unix(),
-		Hostname:     hostname,
-		AgentVersion: "1.0.0",
-	}
-
-	stats.CPU = c.collectCPU()
-	stats.Memory = c.collectMemory()
-	stats.Disk = c.collectDisk()
-	stats.Network = c.collectNetwork()
-	stats.Thermals = c.collectThermals()
-	stats.GPU = c.collectGPU()
-	stats.Features = c.collectFeatures()
-
-	if len(c.errors) > 0 {
-		stats.Errors = c.errors
-	}
-
-	return stats
-}
-
-func (c *Collector) collectCPU() *CPUStats {
-	stats := &CPUStats{Available: false}
-
-	// Parse /proc/stat
-	data, err := os.ReadFile(filepath.Join(c.procPath, "stat"))
-	if err != nil {
-		c.logError("cpu", fmt.Sprintf("failed to read /proc/stat: %v", err))
-		return stats
-	}
-
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(line, "cpu ") {
-			fields := strings.Fields(line)
-			if len(fields) < 9 {
-				break
-			}
-
-			user, _ := strconv.ParseUint(fields[1], 10, 64)
-			nice, _ := strconv.ParseUint(fields[2], 10, 64)
-			system, _ := strconv.ParseUint(fields[3], 10, 64)
-			idle, _ := strconv.ParseUint(fields[4], 10, 64)
-			iowait, _ := strconv.ParseUint(fields[5], 10, 64)
-			irq, _ := strconv.ParseUint(fields[6], 10, 64)
-			softirq, _ := strconv.ParseUint(fields[7], 10, 64)
-			steal, _ := strconv.ParseUint(fields[8], 10, 64)
-
-			total := user + nice + system + idle + iowait + irq + softirq + steal
-
-			now := time.Now()
-			if c.prevCPU != nil {
-				deltaTotal := float64(total - c.prevCPU.total)
-				deltaIdle := float64(idle - c.prevCPU.idle)
-				deltaIowait := float64(iowait - c.prevCPU.iowait)
-				deltaSteal := float64(steal - c.prevCPU.steal)
-
-				if deltaTotal > 0 {
-					usage := ((deltaTotal - deltaIdle) / deltaTotal) * 100.0
-					iowaitPct := (deltaIowait / deltaTotal) * 100.0
-					stealPct := (deltaSteal / deltaTotal) * 100.0
-
-					stats.UsagePercent = &usage
-					stats.IowaitPercent = &iowaitPct
-					stats.StealPercent = &stealPct
-					stats.Available = true
-				}
-			}
-
-			c.prevCPU = &cpuSnapshot{
-				timestamp: now,
-				total:     total,
-				idle:      idle,
-				iowait:    iowait,
-				steal:     steal,
-			}
-			break
-		}
+		c.log.Info("using /host/sys for TrueNAS SCALE compatibility")
 	}
 
-	// Core count
-	coreCount := c.getCoreCount()
-	if coreCount > 0 {
-		stats.CoreCount = &coreCount
-	}
-
-	// Load average
-	if loadData, err := os.ReadFile(filepath.Join(c.procPath, "loadavg")); err == nil {
-		fields := strings.Fields(string(loadData))
-		if len(fields) >= 3 {
-			if load1, err := strconv.ParseFloat(fields[0], 64); err == nil {
-				stats.Loadavg1 = &load1
-			}
-			if load5, err := strconv.ParseFloat(fields[1], 64); err == nil {
-				stats.Loadavg5 = &load5
-			}
-			if load15, err := strconv.ParseFloat(fields[2], 64); err == nil {
-				stats.Loadavg15 = &load15
-			}
-		}
-	}
-
-	return stats
-}
+	c.log.Info("monitoring paths", "proc", c.procPath, "sys", c.sysPath)
 
-func (c *Collector) getCoreCount() int {
-	data, err := os.ReadFile(filepath.Join(c.procPath, "cpuinfo"))
+	watchers, err := newWatchers(opts.Watchlist)
 	if err != nil {
-		return 0
+		c.log.Warn("skipping invalid watch entries", "error", err)
 	}
+	c.watchers = watchers
+	c.watchCmdlines = make(map[string]string)
 
-	count := 0
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(line, "processor") {
-			count++
-		}
-	}
-	return count
+	c.registerBuiltinSources()
+	return c
 }
 
-func (c *Collector) collectMemory() *MemoryStats {
-	stats := &MemoryStats{Available: false}
-
-	data, err := os.ReadFile(filepath.Join(c.procPath, "meminfo"))
-	if err != nil {
-		c.logError("memory", fmt.Sprintf("failed to read /proc/meminfo: %v", err))
-		return stats
-	}
-
-	memInfo := make(map[string]uint64)
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
-			continue
-		}
-		key := strings.TrimSuffix(fields[0], ":")
-		val, _ := strconv.ParseUint(fields[1], 10, 64)
-		// Convert from kB to bytes
-		memInfo[key] = val * 1024
-	}
+// Default cadences for sources whose data changes slowly or is expensive
+// to fetch. Sources never run faster than the configured interval.
+const (
+	filesystemsInterval = time.Minute
+	externalIPInterval  = 10 * time.Minute
+	gpuInterval         = time.Minute
+	featuresInterval    = time.Minute
+	tasksInterval       = 5 * time.Second
+	processesInterval   = 5 * time.Second
+	watchInterval       = 5 * time.Second
+)
 
-	if total, ok := memInfo["MemTotal"]; ok {
-		stats.TotalBytes = &total
-		stats.Available = true
-	}
-	if avail, ok := memInfo["MemAvailable"]; ok {
-		stats.AvailableBytes = &avail
-	}
-	if buffers, ok := memInfo["Buffers"]; ok {
-		stats.BuffersBytes = &buffers
-	}
-	if cached, ok := memInfo["Cached"]; ok {
-		stats.CachedBytes = &cached
-	}
-	if swapTotal, ok := memInfo["SwapTotal"]; ok {
-		stats.SwapTotalBytes = &swapTotal
-	}
-	if swapFree, ok := memInfo["SwapFree"]; ok {
-		if swapTotal, ok := memInfo["SwapTotal"]; ok {
-			swapUsed := swapTotal - swapFree
-			stats.SwapUsedBytes = &swapUsed
+// registerBuiltinSources registers the collectors shipped with the agent.
+// The order here is the order sections are merged in.
+func (c *Collector) registerBuiltinSources() {
+	builtins := []Source{
+		NewSource("cpu", c.interval, func(ctx context.Context, s *RemoteLinuxStats) { s.CPU = c.collectCPU(ctx) }),
+		NewSource("memory", c.interval, func(ctx context.Context, s *RemoteLinuxStats) { s.Memory = c.collectMemory(ctx) }),
+		NewSource("disk", c.interval, func(ctx context.Context, s *RemoteLinuxStats) { s.Disk = c.collectDisk(ctx) }),
+		NewSource("filesystems", c.slower(filesystemsInterval), func(ctx context.Context, s *RemoteLinuxStats) {
+			s.Disk = c.collectFilesystemUsage(ctx)
+		}),
+		NewSource("network", c.interval, func(ctx context.Context, s *RemoteLinuxStats) { s.Network = c.collectNetwork(ctx) }),
+		NewSource("external_ip", c.slower(externalIPInterval), func(ctx context.Context, s *RemoteLinuxStats) {
+			s.Network = c.collectExternalIP(ctx)
+		}),
+		NewSource("thermals", c.interval, func(ctx context.Context, s *RemoteLinuxStats) { s.Thermals = c.collectThermals(ctx) }),
+		NewSource("gpu", c.slower(gpuInterval), func(ctx context.Context, s *RemoteLinuxStats) { s.GPU = c.collectGPU(ctx) }),
+		NewSource("features", c.slower(featuresInterval), func(ctx context.Context, s *RemoteLinuxStats) {
+			s.Features = c.collectFeatures(ctx)
+		}),
+		NewSource("pressure", c.interval, func(ctx context.Context, s *RemoteLinuxStats) { s.Pressure = c.collectPressure(ctx) }),
+		NewSource("tasks", c.slower(tasksInterval), func(ctx context.Context, s *RemoteLinuxStats) { s.Tasks = c.collectTasks(ctx) }),
+		NewSource("interrupts", c.interval, func(ctx context.Context, s *RemoteLinuxStats) { s.Interrupts = c.collectInterrupts(ctx) }),
+		NewSource("processes", c.slower(processesInterval), func(ctx context.Context, s *RemoteLinuxStats) {
+			s.Processes, s.Groups = c.collectProcesses(ctx)
+		}),
+		NewSource("watch", c.slower(watchInterval), func(ctx context.Context, s *RemoteLinuxStats) { s.Watch = c.collectWatch(ctx) }),
+		NewSource("self", c.interval, func(ctx context.Context, s *RemoteLinuxStats) { s.Self = c.collectSelf(ctx) }),
+	}
+	for _, source := range builtins {
+		if err := c.registry.Register(source); err != nil {
+			panic(err) // Built-in names are unique
 		}
 	}
-	if swapCached, ok := memInfo["SwapCached"]; ok {
-		stats.SwapCachedBytes = &swapCached
-	}
-
-	// Calculate used bytes
-	if stats.TotalBytes != nil && stats.AvailableBytes != nil {
-		used := *stats.TotalBytes - *stats.AvailableBytes
-		stats.UsedBytes = &used
-	}
-
-	// PSI (Pressure Stall Information)
-	c.collectPSI(stats)
-
-	return stats
 }
 
-func (c *Collector) collectPSI(stats *MemoryStats) {
-	data, err := os.ReadFile(filepath.Join(c.procPath, "pressure/memory"))
-	if err != nil {
-		return // PSI is optional
-	}
-
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(line, "some ") {
-			parts := strings.Fields(line)
-			for _, part := range parts {
-				if strings.HasPrefix(part, "avg10=") {
-					if val, err := strconv.ParseFloat(strings.TrimPrefix(part, "avg10="), 64); err == nil {
-						stats.PsiMemAvg10 = &val
-					}
-				} else if strings.HasPrefix(part, "avg60=") {
-					if val, err := strconv.ParseFloat(strings.TrimPrefix(part, "avg60="), 64); err == nil {
-						stats.PsiMemAvg60 = &val
-					}
-				} else if strings.HasPrefix(part, "avg300=") {
-					if val, err := strconv.ParseFloat(strings.TrimPrefix(part, "avg300="), 64); err == nil {
-						stats.PsiMemAvg300 = &val
-					}
-				}
-			}
-		}
+// slower returns d, or the collector interval if that is longer.
+func (c *Collector) slower(d time.Duration) time.Duration {
+	if c.interval > d {
+		return c.interval
 	}
+	return d
 }
 
-func (c *Collector) collectDisk() *DiskStats {
-	stats := &DiskStats{Available: false}
-
-	// Collect disk I/O stats
-	devices := c.collectDiskDevices()
-	if len(devices) > 0 {
-		stats.Devices = devices
-		stats.Available = true
-	}
-
-	// Collect filesystem stats
-	filesystems := c.collectFilesystems()
-	if len(filesystems) > 0 {
-		stats.Filesystems = filesystems
-		stats.Available = true
-	}
-
-	return stats
+// Registry returns the collector's source registry, used to register
+// additional sources or enable and disable existing ones.
+func (c *Collector) Registry() *Registry {
+	return c.registry
 }
 
-func (c *Collector) collectDiskDevices() []DiskDevice {
-	data, err := os.ReadFile(filepath.Join(c.procPath, "diskstats"))
-	if err != nil {
-		c.logError("disk", fmt.Sprintf("failed to read /proc/diskstats: %v", err))
-		return nil
+// Collect returns a snapshot merged from the latest result of every enabled
+// source. It never runs collectors itself, so it returns immediately even
+// if a source is slow or hung; see Start for the sampler.
+func (c *Collector) Collect() *RemoteLinuxStats {
+	hostname, _ := os.Hostname()
+	stats := &RemoteLinuxStats{
+		Schema:       "v1",
+		Timestamp:    time.Now().Unix(),
+		Hostname:     hostname,
+		AgentVersion: "1.0.0",
 	}
 
-	var devices []DiskDevice
-	now := time.Now()
-	lines := strings.Split(string(data), "\n")
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	for _, line := range lines {
-		fields := strings.Fields(line)
-		if len(fields) < 14 {
-			continue
-		}
-
-		name := fields[2]
-		// Skip loop, ram, and partition devices
-		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+	for _, source := range c.registry.Enabled() {
+		result, ok := c.results[source.Name()]
+		if !ok {
 			continue
 		}
-		
-		// Skip partitions - allow whole disks only
-		// Examples: sda, nvme0n1 are OK; sda1, nvme0n1p1 are not
-		if len(name) > 0 && name[len(name)-1] >= '0' && name[len(name)-1] <= '9' {
-			// Special case for NVMe: nvme0n1 is a whole disk (not a partition)
-			// NVMe partitions are nvme0n1p1, nvme0n1p2, etc.
-			isNvmeWholeDisk := strings.HasPrefix(name, "nvme") && 
-				strings.Contains(name, "n") && 
-				!strings.Contains(name, "p")
-			if !isNvmeWholeDisk {
-				continue
+		if result.part != nil {
+			mergeSections(stats, result.part)
+			// A section filled by several sources carries the newest of
+			// their times; each source's own time is listed separately
+			collectedAt := result.collectedAt.UnixMilli()
+			if stats.CollectedAt == nil {
+				stats.CollectedAt = make(map[string]int64)
+				stats.SourceCollectedAt = make(map[string]int64)
 			}
-		}
-
-		readOps, _ := strconv.ParseUint(fields[3], 10, 64)
-		readSectors, _ := strconv.ParseUint(fields[5], 10, 64)
-		writeOps, _ := strconv.ParseUint(fields[7], 10, 64)
-		writeSectors, _ := strconv.ParseUint(fields[9], 10, 64)
-
-		readBytes := readSectors * 512
-		writeBytes := writeSectors * 512
-
-		device := DiskDevice{Name: name}
-
-		if prev, ok := c.prevDisk[name]; ok {
-			elapsed := now.Sub(prev.timestamp).Seconds()
-			if elapsed > 0 {
-				readBytesPerSec := float64(readBytes-prev.readBytes) / elapsed
-				writeBytesPerSec := float64(writeBytes-prev.writeBytes) / elapsed
-				readsPerSec := float64(readOps-prev.readOps) / elapsed
-				writesPerSec := float64(writeOps-prev.writeOps) / elapsed
-
-				device.ReadBytesPerSec = &readBytesPerSec
-				device.WriteBytesPerSec = &writeBytesPerSec
-				device.ReadsPerSec = &readsPerSec
-				device.WritesPerSec = &writesPerSec
+			stats.SourceCollectedAt[source.Name()] = collectedAt
+			for _, section := range result.sections {
+				key := sectionJSONName(section)
+				if at, ok := stats.CollectedAt[key]; !ok || collectedAt > at {
+					stats.CollectedAt[key] = collectedAt
+				}
 			}
 		}
-
-		c.prevDisk[name] = &diskSnapshot{
-			timestamp:  now,
-			readBytes:  readBytes,
-			writeBytes: writeBytes,
-			readOps:    readOps,
-			writeOps:   writeOps,
-		}
-
-		devices = append(devices, device)
 	}
 
-	return devices
-}
-
-func (c *Collector) collectFilesystems() []Filesystem {
-	file, err := os.Open(filepath.Join(c.procPath, "mounts"))
-	if err != nil {
-		c.logError("filesystem", fmt.Sprintf("failed to read /proc/mounts: %v", err))
-		return nil
-	}
-	defer file.Close()
-	// First pass: collect mount entries and their raw statfs values
-	type mountEntry struct {
-		fs Filesystem
-		total uint64
-		available uint64
-		free uint64
-		used uint64
-	}
-
-	entries := make([]mountEntry, 0)
-	scanner := bufio.NewScanner(file)
-	seen := make(map[string]bool)
-
-	// skip types map
-	skipTypes := map[string]bool{
-		"proc": true, "sysfs": true, "tmpfs": true, "devtmpfs": true, "devpts": true,
-		"cgroup": true, "cgroup2": true, "nsfs": true, "overlay": true, "squashfs": true,
-		"autofs": true, "mqueue": true, "hugetlbfs": true, "debugfs": true, "tracefs": true,
-		"securityfs": true, "pstore": true, "bpf": true, "configfs": true, "fusectl": true,
-		"binfmt_misc": true, "ramfs": true, "efivarfs": true, "rpc_pipefs": true,
-	}
-
-	for scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
-		if len(fields) < 3 {
-			continue
-		}
-
-		device := fields[0]
-		mountPoint := fields[1]
-		fsType := fields[2]
-
-		if skipTypes[fsType] {
-			continue
+	// Errors are listed flat for older clients and attached to the
+	// sections their source fills in.
+	for _, source := range c.registry.Enabled() {
+		errs := c.errors.forSource(source.Name())
+		for _, e := range errs {
+			stats.Errors = append(stats.Errors, e.String())
 		}
-
-		if seen[mountPoint] {
-			continue
+		if result, ok := c.results[source.Name()]; ok && len(errs) > 0 {
+			attachSectionErrors(stats, result.sections, errs)
 		}
-		seen[mountPoint] = true
-
-		fs := Filesystem{
-			MountPoint: mountPoint,
-			Device:     device,
-			FsType:     &fsType,
-		}
-
-		var total, available, free, used uint64
-		var stat syscall.Statfs_t
-		if err := syscall.Statfs(mountPoint, &stat); err == nil {
-			total = stat.Blocks * uint64(stat.Bsize)
-			available = stat.Bavail * uint64(stat.Bsize)
-			free = stat.Bfree * uint64(stat.Bsize)
-			used = 0
-			if total > free {
-				used = total - free
-			}
-
-			fs.TotalBytes = &total
-			fs.AvailableBytes = &available
-			fs.UsedBytes = &used
-
-			if total > 0 {
-				usagePercent := (float64(used) / float64(total)) * 100.0
-				fs.UsagePercent = &usagePercent
-			}
-		}
-
-		entries = append(entries, mountEntry{fs: fs, total: total, available: available, free: free, used: used})
 	}
 
-	// Second pass: build final filesystems list with special handling for /mnt/* mounts
-	var filesystems []Filesystem
-	// Determine whether we should apply the TrueNAS /mnt/ special-case.
-	// Can be controlled by env var MENUBAR_TRUENAS_MNT_FIX: "on"/"true"/"1", "off"/"false"/"0", or "auto"/empty.
-	applyTruenasMntFix := false
-	switch strings.ToLower(strings.TrimSpace(os.Getenv("MENUBAR_TRUENAS_MNT_FIX"))) {
-	case "on", "true", "1":
-		applyTruenasMntFix = true
-	case "off", "false", "0":
-		applyTruenasMntFix = false
-	default:
-		applyTruenasMntFix = isLikelyContainer() && isLikelyTrueNAS()
-	}
-	for i := range entries {
-		entry := entries[i]
-		mp := entry.fs.MountPoint
-		if applyTruenasMntFix && strings.HasPrefix(mp, "/mnt/") {
-			// If mountpoint is under /mnt/ and is a child (e.g. /mnt/foo/bar), skip it.
-			rel := strings.TrimPrefix(mp, "/mnt/")
-			if strings.Contains(rel, "/") {
-				// child dataset; skip - its usage will be accumulated into the root
-				continue
-			}
-
-			// For top-level /mnt/<name>, accumulate used from any children
-			var usedSum uint64 = 0
-			for j := range entries {
-				child := entries[j]
-				if child.fs.MountPoint == mp {
-					if child.used != 0 {
-						usedSum += child.used
-					}
-					continue
-				}
-				if strings.HasPrefix(child.fs.MountPoint, mp+"/") {
-					if child.used != 0 {
-						usedSum += child.used
-					}
-				}
-			}
-
-			// If no children contributed used, fall back to the root entry's used value
-			if usedSum == 0 {
-				usedSum = entry.used
-			}
-
-			// Available bytes - prefer the root's available value
-			avail := entry.available
-
-			// Compute total as used + available (handles TrueNAS display quirk)
-			total := usedSum + avail
-
-			fs := entry.fs
-			fs.UsedBytes = &usedSum
-			fs.AvailableBytes = &avail
-			fs.TotalBytes = &total
-			if total > 0 {
-				usagePercent := (float64(usedSum) / float64(total)) * 100.0
-				fs.UsagePercent = &usagePercent
-			}
-
-			filesystems = append(filesystems, fs)
-			continue
-		}
-
-		// Non-/mnt mounts or when fix not applied: keep as-is
-		filesystems = append(filesystems, entry.fs)
-	}
-
-	return filesystems
-}
-
-// isLikelyContainer returns true when the process appears to be running inside a container.
-func isLikelyContainer() bool {
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		return true
-	}
-	if data, err := os.ReadFile("/proc/1/cgroup"); err == nil {
-		s := string(data)
-		if strings.Contains(s, "docker") || strings.Contains(s, "containerd") || strings.Contains(s, "kubepods") {
-			return true
-		}
-	}
-	return false
+	return stats
 }
 
-// isLikelyTrueNAS does a best-effort check for TrueNAS SCALE environment.
-func isLikelyTrueNAS() bool {
-	if _, err := os.Stat("/etc/truenas-release"); err == nil {
-		return true
+// runSource collects one source into a fresh partial snapshot, returning
+// the errors it reported. If the source misses its deadline it is
+// abandoned: its goroutine keeps running in the background and the source
+// is skipped until it returns, so a blocked syscall can never pile up
+// goroutines or wedge later collections. The part is nil if the run timed
+// out or was skipped.
+func (c *Collector) runSource(source Source) (*RemoteLinuxStats, []AgentError) {
+	name := source.Name()
+
+	c.runningMu.Lock()
+	if c.running[name] {
+		c.runningMu.Unlock()
+		return nil, []AgentError{{
+			Component: name,
+			Code:      ErrCodeStillRunning,
+			Message:   "still running from a previous collection, skipped",
+		}}
+	}
+	c.running[name] = true
+	c.runningMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	sink := &errorSink{}
+	ctx = context.WithValue(ctx, errorSinkKey{}, sink)
+
+	done := make(chan *RemoteLinuxStats, 1)
+	go func() {
+		defer func() {
+			c.runningMu.Lock()
+			delete(c.running, name)
+			c.runningMu.Unlock()
+		}()
+		part := &RemoteLinuxStats{}
+		source.Collect(ctx, part)
+		done <- part
+	}()
+
+	select {
+	case part := <-done:
+		return part, sink.errors()
+	case <-ctx.Done():
+		return nil, append(sink.errors(), AgentError{
+			Component: name,
+			Code:      ErrCodeTimeout,
+			Message:   fmt.Sprintf("timed out after %s", c.timeout),
+		})
 	}
-	if data, err := os.ReadFile("/etc/os-release"); err == nil {
-		s := strings.ToLower(string(data))
-		if strings.Contains(s, "truenas") {
-			return true
-		}
-	}
-	return false
 }
 
-func (c *Collector) collectNetwork() *NetworkStats {
-	stats := &NetworkStats{Available: false}
-
-	data, err := os.ReadFile(filepath.Join(c.procPath, "net/dev"))
-	if err != nil {
-		c.logError("network", fmt.Sprintf("failed to read /proc/net/dev: %v", err))
-		return stats
-	}
-
-	var interfaces []NetworkInterface
-	now := time.Now()
-	lines := strings.Split(string(data), "\n")
-
-	for i, line := range lines {
-		if i < 2 { // Skip header lines
-			continue
-		}
-
-		parts := strings.Split(line, ":")
-		if len(parts) != 2 {
+// mergeSections merges every section src filled in into dst. Sections are
+// copied before being written to, so cached source results are never
+// modified. When two sources share a section (disk and filesystems, network
+// and external_ip), each contributes the fields it set.
+func mergeSections(dst, src *RemoteLinuxStats) {
+	dv := reflect.ValueOf(dst).Elem()
+	sv := reflect.ValueOf(src).Elem()
+	for i := 0; i < sv.NumField(); i++ {
+		field := sv.Field(i)
+		if field.Kind() != reflect.Ptr || field.IsNil() || field.Elem().Kind() != reflect.Struct {
 			continue
 		}
 
-		name := strings.TrimSpace(parts[0])
-		if name == "lo" { // Skip loopback
+		target := dv.Field(i)
+		if target.IsNil() {
+			section := reflect.New(field.Elem().Type())
+			section.Elem().Set(field.Elem())
+			target.Set(section)
 			continue
 		}
 
-		fields := strings.Fields(parts[1])
-		if len(fields) < 16 {
-			continue
-		}
-
-		rxBytes, _ := strconv.ParseUint(fields[0], 10, 64)
-		txBytes, _ := strconv.ParseUint(fields[8], 10, 64)
-
-		iface := NetworkInterface{Name: name}
-
-		if prev, ok := c.prevNetwork[name]; ok {
-			elapsed := now.Sub(prev.timestamp).Seconds()
-			if elapsed > 0 {
-				rxBytesPerSec := float64(rxBytes-prev.rxBytes) / elapsed
-				txBytesPerSec := float64(txBytes-prev.txBytes) / elapsed
-
-				iface.RxBytesPerSec = &rxBytesPerSec
-				iface.TxBytesPerSec = &txBytesPerSec
+		from := field.Elem()
+		to := target.Elem()
+		for j := 0; j < from.NumField(); j++ {
+			if !from.Field(j).IsZero() {
+				to.Field(j).Set(from.Field(j))
 			}
 		}
-
-		c.prevNetwork[name] = &networkSnapshot{
-			timestamp: now,
-			rxBytes:   rxBytes,
-			txBytes:   txBytes,
-		}
-
-		// Try to get IP and MAC addresses
-		c.enrichNetworkInterface(&iface)
-
-		interfaces = append(interfaces, iface)
-		stats.Available = true
-	}
-
-	if len(interfaces) > 0 {
-		stats.Interfaces = interfaces
-	}
-
-	// Try to get external IPv4 address (best effort, non-blocking)
-	if externalIP := c.getExternalIPv4(); externalIP != "" {
-		stats.ExternalIPv4 = &externalIP
 	}
-
-	return stats
 }
 
-func (c *Collector) enrichNetworkInterface(iface *NetworkInterface) {
-	// Try to read MAC address
-	macPath := filepath.Join(c.sysPath, "class/net", iface.Name, "address")
-	if data, err := os.ReadFile(macPath); err == nil {
-		mac := strings.TrimSpace(string(data))
-		if mac != "" && mac != "00:00:00:00:00:00" {
-			iface.MacAddress = &mac
-		}
-	}
-
-	// Try to get IP addresses using the 'ip' command first (works in containers)
-	// This is more reliable when running in Docker/TrueNAS environments
-	if c.getIPAddressesViaIPCommand(iface) {
-		return
-	}
-
-	// Fallback to Go's net package
-	netIface, err := net.InterfaceByName(iface.Name)
-	if err != nil {
-		return
-	}
-
-	addrs, err := netIface.Addrs()
-	if err != nil {
-		return
-	}
-
-	for _, addr := range addrs {
-		ipNet, ok := addr.(*net.IPNet)
-		if !ok {
-			continue
-		}
-
-		ip := ipNet.IP
-		// Skip loopback addresses
-		if ip.IsLoopback() {
-			continue
-		}
-
-		// Check if IPv4 or IPv6
-		if ipv4 := ip.To4(); ipv4 != nil {
-			// IPv4 address
-			if iface.Ipv4Address == nil {
-				ipv4Str := ipv4.String()
-				iface.Ipv4Address = &ipv4Str
-			}
-		} else if ipv6 := ip.To16(); ipv6 != nil {
-			// IPv6 address (skip link-local)
-			if !ip.IsLinkLocalUnicast() && iface.Ipv6Address == nil {
-				ipv6Str := ipv6.String()
-				iface.Ipv6Address = &ipv6Str
-			}
+// sectionsOf returns the names of the sections part filled in.
+func sectionsOf(part *RemoteLinuxStats) []string {
+	var sections []string
+	v := reflect.ValueOf(part).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Ptr && !field.IsNil() && field.Elem().Kind() == reflect.Struct {
+			sections = append(sections, v.Type().Field(i).Name)
 		}
 	}
+	return sections
 }
 
-// getIPAddressesViaIPCommand uses the 'ip' command to get IP addresses
-// This works better in containerized environments (e.g., TrueNAS SCALE)
-func (c *Collector) getIPAddressesViaIPCommand(iface *NetworkInterface) bool {
-	// Validate interface name to prevent command injection
-	// Interface names should only contain alphanumeric, hyphens, underscores, and dots
-	if !isValidInterfaceName(iface.Name) {
-		return false
+// sectionJSONName returns the JSON name of the section field name.
+func sectionJSONName(name string) string {
+	field, ok := reflect.TypeOf(RemoteLinuxStats{}).FieldByName(name)
+	if !ok {
+		return name
 	}
-	
-	// Execute 'ip addr show <interface>'
-	cmd := exec.Command("ip", "addr", "show", iface.Name)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return false
+	key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if key == "" {
+		return name
 	}
-
-	// Parse the output for inet and inet6 addresses
-	lines := strings.Split(string(output), "\n")
-	foundAny := false
-	
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		
-		// Look for "inet" lines
-		if strings.HasPrefix(line, "inet ") {
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				// Extract IP address (remove /prefix if present)
-				ipWithMask := fields[1]
-				ip := ipWithMask
-				if idx := strings.Index(ipWithMask, "/"); idx != -1 {
-					ip = ipWithMask[:idx]
-				}
-				
-				// Validate and store IPv4
-				if parsedIP := net.ParseIP(ip); parsedIP != nil && parsedIP.To4() != nil {
-					if !parsedIP.IsLoopback() && iface.Ipv4Address == nil {
-						iface.Ipv4Address = &ip
-						foundAny = true
-					}
-				}
-			}
-		} else if strings.HasPrefix(line, "inet6 ") {
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				// Extract IPv6 address (remove /prefix if present)
-				ipWithMask := fields[1]
-				ip := ipWithMask
-				if idx := strings.Index(ipWithMask, "/"); idx != -1 {
-					ip = ipWithMask[:idx]
-				}
-				
-				// Validate and store IPv6 (skip link-local)
-				if parsedIP := net.ParseIP(ip); parsedIP != nil {
-					if !parsedIP.IsLoopback() && !parsedIP.IsLinkLocalUnicast() && iface.Ipv6Address == nil {
-						iface.Ipv6Address = &ip
-						foundAny = true
-					}
-				}
-			}
-		}
-	}
-	
-	return foundAny
+	return key
 }
 
-// isValidInterfaceName checks if an interface name is safe to use in commands
-// Prevents command injection by ensuring only valid characters are present
-func isValidInterfaceName(name string) bool {
-	if name == "" || len(name) > 15 { // Linux interface names are max 15 chars
-		return false
-	}
-	
-	for _, ch := range name {
-		if !((ch >= 'a' && ch <= 'z') ||
-			(ch >= 'A' && ch <= 'Z') ||
-			(ch >= '0' && ch <= '9') ||
-			ch == '-' || ch == '_' || ch == '.') {
-			return false
+// anyAvailable reports whether a section part filled in has Available set,
+// or has no Available field at all.
+func anyAvailable(part *RemoteLinuxStats) bool {
+	v := reflect.ValueOf(part).Elem()
+	for _, name := range sectionsOf(part) {
+		available := v.FieldByName(name).Elem().FieldByName("Available")
+		if !available.IsValid() || available.Kind() != reflect.Bool || available.Bool() {
+			return true
 		}
 	}
-	
-	return true
+	return false
 }
 
-func (c *Collector) collectThermals() *ThermalStats {
-	stats := &ThermalStats{Available: false}
-
-	hwmonPath := filepath.Join(c.sysPath, "class/hwmon")
-	entries, err := os.ReadDir(hwmonPath)
-	if err != nil {
-		return stats
-	}
-
-	var sensors []ThermalSensor
-	for _, entry := range entries {
-		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "hwmon") {
+// attachSectionErrors appends errs to the Errors field of each named
+// section of s. The sections must be copies owned by s.
+func attachSectionErrors(s *RemoteLinuxStats, sections []string, errs []AgentError) {
+	v := reflect.ValueOf(s).Elem()
+	for _, name := range sections {
+		section := v.FieldByName(name)
+		if section.Kind() != reflect.Ptr || section.IsNil() {
 			continue
 		}
-
-		hwmonDir := filepath.Join(hwmonPath, entry.Name())
-		tempFiles, _ := filepath.Glob(filepath.Join(hwmonDir, "temp*_input"))
-
-		for _, tempFile := range tempFiles {
-			data, err := os.ReadFile(tempFile)
-			if err != nil {
-				continue
-			}
-
-			tempMilliC, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
-			if err != nil {
-				continue
-			}
-
-			tempC := float64(tempMilliC) / 1000.0
-			if tempC < -100 || tempC > 200 { // Sanity check
-				continue
-			}
-
-			// Extract sensor number from filename
-			baseName := filepath.Base(tempFile)
-			sensorNum := strings.TrimSuffix(strings.TrimPrefix(baseName, "temp"), "_input")
-
-			sensor := ThermalSensor{
-				Name:        fmt.Sprintf("%s_temp%s", entry.Name(), sensorNum),
-				TempCelsius: &tempC,
-			}
-
-			// Try to read label
-			labelFile := filepath.Join(hwmonDir, fmt.Sprintf("temp%s_label", sensorNum))
-			if labelData, err := os.ReadFile(labelFile); err == nil {
-				label := strings.TrimSpace(string(labelData))
-				sensor.Label = &label
-			}
-
-			// Try to read critical temp
-			critFile := filepath.Join(hwmonDir, fmt.Sprintf("temp%s_crit", sensorNum))
-			if critData, err := os.ReadFile(critFile); err == nil {
-				if critMilliC, err := strconv.ParseInt(strings.TrimSpace(string(critData)), 10, 64); err == nil {
-					critC := float64(critMilliC) / 1000.0
-					sensor.CriticalTemp = &critC
-				}
-			}
-
-			// Try to read max temp
-			maxFile := filepath.Join(hwmonDir, fmt.Sprintf("temp%s_max", sensorNum))
-			if maxData, err := os.ReadFile(maxFile); err == nil {
-				if maxMilliC, err := strconv.ParseInt(strings.TrimSpace(string(maxData)), 10, 64); err == nil {
-					maxC := float64(maxMilliC) / 1000.0
-					sensor.MaxTemp = &maxC
-				}
-			}
-
-			sensors = append(sensors, sensor)
-			stats.Available = true
+		field := section.Elem().FieldByName("Errors")
+		if !field.IsValid() {
+			continue
 		}
+		field.Set(reflect.AppendSlice(field, reflect.ValueOf(errs)))
 	}
-
-	if len(sensors) > 0 {
-		stats.Sensors = sensors
-	}
-
-	return stats
 }
 
-func (c *Collector) collectGPU() *GPUStats {
-	// GPU monitoring requires vendor-specific tools (nvidia-smi, rocm-smi, etc.)
-	// Not available without external dependencies
-	return &GPUStats{Available: false}
-}
-
-func (c *Collector) collectFeatures() *Features {
-	features := &Features{}
-
-	// SMART - check if smartctl or any SMART tools exist
-	smartAvail := false
-	features.SmartAvailable = &smartAvail
-
-	// NVMe - check for NVMe devices
-	nvmeAvail := c.checkNVMeAvailable()
-	features.NvmeAvailable = &nvmeAvail
+type errorSinkKey struct{}
 
-	// Thermal - check if we found any thermal sensors
-	thermalAvail := c.collectThermals().Available
-	features.ThermalAvailable = &thermalAvail
-
-	// GPU - always false without external tools
-	gpuAvail := false
-	features.GpuAvailable = &gpuAvail
-
-	return features
+// errorSink gathers the errors reported during one source run.
+type errorSink struct {
+	mu   sync.Mutex
+	errs []AgentError
 }
 
-func (c *Collector) checkNVMeAvailable() bool {
-	data, err := os.ReadFile(filepath.Join(c.procPath, "diskstats"))
-	if err != nil {
-		return false
-	}
-	return strings.Contains(string(data), "nvme")
+func (s *errorSink) add(e AgentError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, e)
 }
 
-func (c *Collector) logError(component, message string) {
-	key := component + ":" + message
-	if !c.loggedErrors[key] {
-		log.Printf("warning: %s: %s", component, message)
-		c.loggedErrors[key] = true
-	}
-	c.errors = append(c.errors, fmt.Sprintf("%s: %s", component, message))
+func (s *errorSink) errors() []AgentError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]AgentError(nil), s.errs...)
 }
 
-// getExternalIPv4 attempts to fetch the external IPv4 address using a public IP service
-// Returns empty string on failure (best effort, non-blocking with timeout)
-func (c *Collector) getExternalIPv4() string {
-	// Use a short timeout to avoid blocking stats collection
-	client := &http.Client{
-		Timeout: 2 * time.Second,
-	}
-
-	// Try multiple services in case one is down
-	services := []string{
-		"https://api.ipify.org",
-		"https://icanhazip.com",
-		"https://ifconfig.me/ip",
-	}
-
-	for _, service := range services {
-		resp, err := client.Get(service)
-		if err != nil {
-			continue
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			continue
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			continue
-		}
-
-		ip := strings.TrimSpace(string(body))
-		// Validate it's a proper IPv4 address
-		if parsedIP := net.ParseIP(ip); parsedIP != nil && parsedIP.To4() != nil {
-			return ip
-		}
+// logError reports an error for the source run in ctx. It is logged when
+// it first appears and again when a later run no longer reports it.
+func (c *Collector) logError(ctx context.Context, component, code, message string) {
+	if sink, ok := ctx.Value(errorSinkKey{}).(*errorSink); ok {
+		sink.add(AgentError{Component: component, Code: code, Message: message})
 	}
-
-	return ""
 }
@@ -0,0 +1,121 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// wrap32 is where 32-bit kernel counters (some NIC drivers, 32-bit kernels)
+// wrap back to zero.
+const wrap32 = 1 << 32
+
+// counterStatus describes how a counter reading relates to the previous one.
+type counterStatus int
+
+const (
+	counterOK    counterStatus = iota // Delta is valid
+	counterFirst                      // No previous reading to compare with
+	counterReset                      // Counter went backwards or the host rebooted; reading is a new baseline
+)
+
+type counterSample struct {
+	value  uint64
+	at     time.Time
+	bootID string
+}
+
+// counterTracker turns successive readings of monotonically increasing
+// kernel counters into deltas. Counters are not as monotonic as they look:
+// an interface is recreated, a disk is hot-swapped, a CPU goes offline or
+// the host reboots, and plain uint64 subtraction underflows into exabytes
+// per second. The tracker recognizes 32-bit wraps, treats every other
+// backwards step as a reset and never reports a delta across one.
+//
+// A tracker belongs to one source and is only used from that source's run,
+// so it needs no locking.
+type counterTracker struct {
+	samples map[string]counterSample
+}
+
+func newCounterTracker() *counterTracker {
+	return &counterTracker{samples: make(map[string]counterSample)}
+}
+
+// delta records a reading of the counter key and returns the increase since
+// the previous reading and the time between them. The delta is only
+// meaningful when the status is counterOK. bootID may be empty if unknown.
+func (t *counterTracker) delta(key string, value uint64, now time.Time, bootID string) (uint64, time.Duration, counterStatus) {
+	prev, ok := t.samples[key]
+	t.samples[key] = counterSample{value: value, at: now, bootID: bootID}
+
+	if !ok {
+		return 0, 0, counterFirst
+	}
+	if prev.bootID != "" && bootID != "" && prev.bootID != bootID {
+		return 0, 0, counterReset
+	}
+	elapsed := now.Sub(prev.at)
+	if elapsed <= 0 {
+		return 0, 0, counterReset
+	}
+	if value >= prev.value {
+		return value - prev.value, elapsed, counterOK
+	}
+
+	// A 32-bit counter that was in the top half of its range and is now in
+	// the bottom half most likely wrapped; anything else going backwards
+	// was reset.
+	if prev.value < wrap32 && prev.value >= wrap32/2 && value < wrap32/2 {
+		return wrap32 - prev.value + value, elapsed, counterOK
+	}
+	return 0, 0, counterReset
+}
+
+// rate records a reading and returns the per-second rate since the previous
+// one, or nil if there is no valid delta. reset reports that the reading
+// follows a reset and only serves as a new baseline.
+func (t *counterTracker) rate(key string, value uint64, now time.Time, bootID string) (rate *float64, reset bool) {
+	delta, elapsed, status := t.delta(key, value, now, bootID)
+	if status != counterOK {
+		return nil, status == counterReset
+	}
+	perSec := float64(delta) / elapsed.Seconds()
+	return &perSec, false
+}
+
+// component records a reading of a counter that is one part of a total
+// tracked with delta, and returns its increase since the previous reading.
+// Some parts legitimately step backwards: the kernel documents that iowait,
+// and idle on NO_HZ kernels, can decrease. A decrease counts as zero here
+// rather than a reset; whether the sample is usable is decided by the
+// total.
+func (t *counterTracker) component(key string, value uint64, now time.Time, bootID string) uint64 {
+	prev, ok := t.samples[key]
+	t.samples[key] = counterSample{value: value, at: now, bootID: bootID}
+	if !ok || value < prev.value {
+		return 0
+	}
+	return value - prev.value
+}
+
+// prune forgets counters that were not read at now, so devices that have
+// gone away don't accumulate.
+func (t *counterTracker) prune(now time.Time) {
+	for key, sample := range t.samples {
+		if !sample.at.Equal(now) {
+			delete(t.samples, key)
+		}
+	}
+}
+
+// bootID returns the kernel's boot ID, which changes on every boot, or ""
+// if it can't be read.
+func (c *Collector) bootID() string {
+	data, err := os.ReadFile(filepath.Join(c.procPath, "sys/kernel/random/boot_id"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,137 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCounterTrackerDelta(t *testing.T) {
+	type reading struct {
+		value  uint64
+		at     int64 // Seconds
+		bootID string
+	}
+	tests := []struct {
+		name        string
+		prev        *reading
+		next        reading
+		wantDelta   uint64
+		wantElapsed time.Duration
+		wantStatus  counterStatus
+	}{
+		{
+			name:       "first reading",
+			next:       reading{value: 100, at: 10},
+			wantStatus: counterFirst,
+		},
+		{
+			name:        "increase",
+			prev:        &reading{value: 100, at: 10, bootID: "a"},
+			next:        reading{value: 250, at: 12, bootID: "a"},
+			wantDelta:   150,
+			wantElapsed: 2 * time.Second,
+			wantStatus:  counterOK,
+		},
+		{
+			name:        "unchanged",
+			prev:        &reading{value: 100, at: 10},
+			next:        reading{value: 100, at: 11},
+			wantElapsed: time.Second,
+			wantStatus:  counterOK,
+		},
+		{
+			name:        "32-bit wrap",
+			prev:        &reading{value: wrap32 - 10, at: 10},
+			next:        reading{value: 5, at: 11},
+			wantDelta:   15,
+			wantElapsed: time.Second,
+			wantStatus:  counterOK,
+		},
+		{
+			name:       "backwards in the bottom half is a reset",
+			prev:       &reading{value: 1000, at: 10},
+			next:       reading{value: 10, at: 11},
+			wantStatus: counterReset,
+		},
+		{
+			name:       "64-bit counter going backwards is a reset",
+			prev:       &reading{value: 1 << 40, at: 10},
+			next:       reading{value: 5, at: 11},
+			wantStatus: counterReset,
+		},
+		{
+			name:       "boot ID changed",
+			prev:       &reading{value: 100, at: 10, bootID: "a"},
+			next:       reading{value: 500, at: 11, bootID: "b"},
+			wantStatus: counterReset,
+		},
+		{
+			name:        "boot ID unknown",
+			prev:        &reading{value: 100, at: 10, bootID: "a"},
+			next:        reading{value: 500, at: 11},
+			wantDelta:   400,
+			wantElapsed: time.Second,
+			wantStatus:  counterOK,
+		},
+		{
+			name:       "no time elapsed",
+			prev:       &reading{value: 100, at: 10},
+			next:       reading{value: 200, at: 10},
+			wantStatus: counterReset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newCounterTracker()
+			if tt.prev != nil {
+				tracker.delta("c", tt.prev.value, time.Unix(tt.prev.at, 0), tt.prev.bootID)
+			}
+			delta, elapsed, status := tracker.delta("c", tt.next.value, time.Unix(tt.next.at, 0), tt.next.bootID)
+			if delta != tt.wantDelta || elapsed != tt.wantElapsed || status != tt.wantStatus {
+				t.Errorf("delta() = %d, %v, %v, want %d, %v, %v", delta, elapsed, status, tt.wantDelta, tt.wantElapsed, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCounterTrackerComponent(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []uint64
+		want   uint64 // Result of the last reading
+	}{
+		{name: "first reading", values: []uint64{50}, want: 0},
+		{name: "increase", values: []uint64{50, 80}, want: 30},
+		{name: "decrease clamps to zero", values: []uint64{50, 40}, want: 0},
+		{name: "increase after a decrease", values: []uint64{50, 40, 45}, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newCounterTracker()
+			var got uint64
+			for i, value := range tt.values {
+				got = tracker.component("iowait", value, time.Unix(int64(i+1), 0), "a")
+			}
+			if got != tt.want {
+				t.Errorf("component() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterTrackerPrune(t *testing.T) {
+	tracker := newCounterTracker()
+	tracker.delta("gone", 1, time.Unix(1, 0), "")
+	tracker.delta("kept", 1, time.Unix(1, 0), "")
+	tracker.delta("kept", 2, time.Unix(2, 0), "")
+	tracker.prune(time.Unix(2, 0))
+
+	if _, ok := tracker.samples["gone"]; ok {
+		t.Error("prune kept a counter that wasn't read")
+	}
+	if _, ok := tracker.samples["kept"]; !ok {
+		t.Error("prune dropped a counter that was read")
+	}
+}
@@ -0,0 +1,262 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cpuTimes are the jiffies one /proc/stat cpu line has spent in each state.
+type cpuTimes struct {
+	user, nice, system, idle, iowait, irq, softirq, steal, guest, guestNice uint64
+}
+
+// total is the time covered by the line. Guest time is already counted in
+// user and nice.
+func (t cpuTimes) total() uint64 {
+	return t.user + t.nice + t.system + t.idle + t.iowait + t.irq + t.softirq + t.steal
+}
+
+// parseCPUTimes parses the fields of a /proc/stat cpu line, name included.
+// The guest columns are missing before Linux 2.6.24 and 2.6.33 and count as
+// zero.
+func parseCPUTimes(fields []string) (cpuTimes, bool) {
+	if len(fields) < 9 {
+		return cpuTimes{}, false
+	}
+	values := make([]uint64, 10)
+	for i := range values {
+		if i+1 >= len(fields) {
+			break
+		}
+		v, err := strconv.ParseUint(fields[i+1], 10, 64)
+		if err != nil {
+			return cpuTimes{}, false
+		}
+		values[i] = v
+	}
+	return cpuTimes{
+		user:      values[0],
+		nice:      values[1],
+		system:    values[2],
+		idle:      values[3],
+		iowait:    values[4],
+		irq:       values[5],
+		softirq:   values[6],
+		steal:     values[7],
+		guest:     values[8],
+		guestNice: values[9],
+	}, true
+}
+
+// cpuShareValues are the percentages of time a CPU spent in each state
+// since the previous sample. Guest time is taken out of user and nice, so
+// user through idle add up to 100.
+type cpuShareValues struct {
+	usage                                   float64
+	user, nice, system, irq, softirq, steal float64
+	guest, guestNice, iowait, idle          float64
+}
+
+// cpuShares runs the counters of one cpu line, keyed by its name, through
+// the counter tracker and returns the percentages since the previous sample.
+// They are only valid when the status is counterOK. Only the total decides
+// whether the sample follows a reset (it went backwards, or the host
+// rebooted); a single state stepping backwards, as iowait and idle may,
+// counts as no time spent in it.
+func (c *Collector) cpuShares(name string, times cpuTimes, now time.Time, bootID string) (cpuShareValues, counterStatus) {
+	_, _, status := c.cpuCounters.delta(name+"/total", times.total(), now, bootID)
+	delta := func(state string, value uint64) uint64 {
+		return c.cpuCounters.component(name+"/"+state, value, now, bootID)
+	}
+
+	deltaUser := delta("user", times.user)
+	deltaNice := delta("nice", times.nice)
+	deltaSystem := delta("system", times.system)
+	deltaIdle := delta("idle", times.idle)
+	deltaIowait := delta("iowait", times.iowait)
+	deltaIrq := delta("irq", times.irq)
+	deltaSoftirq := delta("softirq", times.softirq)
+	deltaSteal := delta("steal", times.steal)
+	deltaGuest := delta("guest", times.guest)
+	deltaGuestNice := delta("guest_nice", times.guestNice)
+
+	if status != counterOK {
+		return cpuShareValues{}, status
+	}
+	// The shares are of the sum of the clamped states, so they add up to
+	// 100 even when one of them stepped backwards
+	deltaTotal := deltaUser + deltaNice + deltaSystem + deltaIdle + deltaIowait + deltaIrq + deltaSoftirq + deltaSteal
+	if deltaTotal == 0 {
+		return cpuShareValues{}, counterFirst
+	}
+
+	// The kernel adds guest time to user and nice separately, so the two
+	// can be a tick apart.
+	deltaGuest = min(deltaGuest, deltaUser)
+	deltaGuestNice = min(deltaGuestNice, deltaNice)
+
+	total := float64(deltaTotal)
+	pct := func(d uint64) float64 { return float64(d) / total * 100.0 }
+	return cpuShareValues{
+		usage:     pct(deltaTotal - deltaIdle),
+		user:      pct(deltaUser - deltaGuest),
+		nice:      pct(deltaNice - deltaGuestNice),
+		system:    pct(deltaSystem),
+		irq:       pct(deltaIrq),
+		softirq:   pct(deltaSoftirq),
+		steal:     pct(deltaSteal),
+		guest:     pct(deltaGuest),
+		guestNice: pct(deltaGuestNice),
+		iowait:    pct(deltaIowait),
+		idle:      pct(deltaIdle),
+	}, counterOK
+}
+
+// parseSchedulerLine fills in the run queue and activity rates from the
+// non-cpu lines of /proc/stat. "processes" counts forks since boot; "intr"
+// is followed by per-IRQ counts, of which only the total is used here.
+func (c *Collector) parseSchedulerLine(stats *CPUStats, fields []string, now time.Time, bootID string) {
+	if len(fields) < 2 {
+		return
+	}
+	value, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return
+	}
+	switch fields[0] {
+	case "procs_running":
+		stats.ProcsRunning = &value
+	case "procs_blocked":
+		stats.ProcsBlocked = &value
+	case "ctxt":
+		stats.ContextSwitchesPerSec, _ = c.cpuCounters.rate("ctxt", value, now, bootID)
+	case "intr":
+		stats.InterruptsPerSec, _ = c.cpuCounters.rate("intr", value, now, bootID)
+	case "processes":
+		stats.ForksPerSec, _ = c.cpuCounters.rate("forks", value, now, bootID)
+	}
+}
+
+func (c *Collector) collectCPU(ctx context.Context) *CPUStats {
+	stats := &CPUStats{Available: false}
+
+	// Parse /proc/stat
+	data, err := os.ReadFile(filepath.Join(c.procPath, "stat"))
+	if err != nil {
+		c.logError(ctx, "cpu", readErrorCode(err), fmt.Sprintf("failed to read /proc/stat: %v", err))
+		return stats
+	}
+
+	// The aggregate "cpu" line comes first, then one "cpuN" line per online
+	// CPU. Offline CPUs have no line, so the set of cores can change between
+	// samples.
+	now := time.Now()
+	bootID := c.bootID()
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "cpu") {
+			c.parseSchedulerLine(stats, strings.Fields(line), now, bootID)
+			continue
+		}
+		fields := strings.Fields(line)
+		times, ok := parseCPUTimes(fields)
+		if !ok {
+			continue
+		}
+
+		if fields[0] == "cpu" {
+			// Offlining a CPU can make the aggregate counters go backwards,
+			// so the sample is only a new baseline if any of them was reset.
+			shares, status := c.cpuShares("cpu", times, now, bootID)
+			if status == counterReset {
+				stats.CounterReset = true
+			}
+			if status == counterOK {
+				stats.UsagePercent = &shares.usage
+				stats.IowaitPercent = &shares.iowait
+				stats.StealPercent = &shares.steal
+				stats.Breakdown = &CPUBreakdown{
+					UserPercent:      shares.user,
+					NicePercent:      shares.nice,
+					SystemPercent:    shares.system,
+					IrqPercent:       shares.irq,
+					SoftirqPercent:   shares.softirq,
+					StealPercent:     shares.steal,
+					GuestPercent:     shares.guest,
+					GuestNicePercent: shares.guestNice,
+					IowaitPercent:    shares.iowait,
+					IdlePercent:      shares.idle,
+				}
+				stats.Available = true
+			}
+			continue
+		}
+
+		id, err := strconv.Atoi(strings.TrimPrefix(fields[0], "cpu"))
+		if err != nil {
+			continue
+		}
+		core := CPUCoreStats{ID: id}
+		shares, status := c.cpuShares(fields[0], times, now, bootID)
+		if status == counterReset {
+			core.CounterReset = true
+		}
+		if status == counterOK {
+			core.UsagePercent = &shares.usage
+			core.IowaitPercent = &shares.iowait
+			core.StealPercent = &shares.steal
+		}
+		stats.Cores = append(stats.Cores, core)
+	}
+	sort.Slice(stats.Cores, func(i, j int) bool { return stats.Cores[i].ID < stats.Cores[j].ID })
+	stats.Packages = c.collectCoreFrequencies(stats.Cores, now, bootID)
+	// Forget cores that went offline; one that comes back starts over with
+	// a fresh baseline.
+	c.cpuCounters.prune(now)
+
+	// Core count
+	coreCount := c.getCoreCount()
+	if coreCount > 0 {
+		stats.CoreCount = &coreCount
+	}
+
+	// Load average
+	if loadData, err := os.ReadFile(filepath.Join(c.procPath, "loadavg")); err == nil {
+		fields := strings.Fields(string(loadData))
+		if len(fields) >= 3 {
+			if load1, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				stats.Loadavg1 = &load1
+			}
+			if load5, err := strconv.ParseFloat(fields[1], 64); err == nil {
+				stats.Loadavg5 = &load5
+			}
+			if load15, err := strconv.ParseFloat(fields[2], 64); err == nil {
+				stats.Loadavg15 = &load15
+			}
+		}
+	}
+
+	return stats
+}
+
+func (c *Collector) getCoreCount() int {
+	data, err := os.ReadFile(filepath.Join(c.procPath, "cpuinfo"))
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "processor") {
+			count++
+		}
+	}
+	return count
+}
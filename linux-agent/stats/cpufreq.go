@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readSysUint reads a sysfs file holding a single unsigned integer.
+func readSysUint(path string) (uint64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// readFreqMHz reads a cpufreq file, which is in kHz.
+func readFreqMHz(path string) *float64 {
+	khz, ok := readSysUint(path)
+	if !ok {
+		return nil
+	}
+	mhz := float64(khz) / 1000.0
+	return &mhz
+}
+
+// collectCoreFrequencies fills in the frequency, governor and thermal
+// throttling of each core from sysfs and returns the throttling of each CPU
+// package. VMs and many ARM boards have no cpufreq or thermal_throttle
+// directory; their fields are left out.
+//
+// Throttle counts are cumulative since boot. The events since the previous
+// sample go through the CPU counter tracker under now, like the /proc/stat
+// counters, so they are pruned together.
+func (c *Collector) collectCoreFrequencies(cores []CPUCoreStats, now time.Time, bootID string) []CPUPackageStats {
+	cpuDir := filepath.Join(c.sysPath, "devices/system/cpu")
+	packages := make(map[int]*CPUPackageStats)
+
+	for i := range cores {
+		core := &cores[i]
+		dir := filepath.Join(cpuDir, "cpu"+strconv.Itoa(core.ID))
+
+		core.FrequencyMHz = readFreqMHz(filepath.Join(dir, "cpufreq/scaling_cur_freq"))
+		core.MinFrequencyMHz = readFreqMHz(filepath.Join(dir, "cpufreq/scaling_min_freq"))
+		core.MaxFrequencyMHz = readFreqMHz(filepath.Join(dir, "cpufreq/scaling_max_freq"))
+		if data, err := os.ReadFile(filepath.Join(dir, "cpufreq/scaling_governor")); err == nil {
+			core.Governor = strings.TrimSpace(string(data))
+		}
+
+		if count, ok := readSysUint(filepath.Join(dir, "thermal_throttle/core_throttle_count")); ok {
+			core.ThrottleCount = &count
+			if events, _, status := c.cpuCounters.delta("cpu"+strconv.Itoa(core.ID)+"/throttle", count, now, bootID); status == counterOK {
+				core.ThrottleEvents = &events
+			}
+		}
+
+		// Every core of a package reports the same package count.
+		count, ok := readSysUint(filepath.Join(dir, "thermal_throttle/package_throttle_count"))
+		if !ok {
+			continue
+		}
+		pkgID := 0
+		if id, ok := readSysUint(filepath.Join(dir, "topology/physical_package_id")); ok {
+			pkgID = int(id)
+		}
+		if _, seen := packages[pkgID]; seen {
+			continue
+		}
+		pkg := &CPUPackageStats{ID: pkgID, ThrottleCount: &count}
+		if events, _, status := c.cpuCounters.delta("package"+strconv.Itoa(pkgID)+"/throttle", count, now, bootID); status == counterOK {
+			pkg.ThrottleEvents = &events
+		}
+		packages[pkgID] = pkg
+	}
+
+	if len(packages) == 0 {
+		return nil
+	}
+	list := make([]CPUPackageStats, 0, len(packages))
+	for _, pkg := range packages {
+		list = append(list, *pkg)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
@@ -0,0 +1,357 @@
+package stats
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// collectDisk returns the fast-changing half of the disk section: per-device
+// I/O rates. Filesystem usage is collected separately at a slower cadence.
+func (c *Collector) collectDisk(ctx context.Context) *DiskStats {
+	stats := &DiskStats{Available: false}
+
+	// Collect disk I/O stats
+	devices := c.collectDiskDevices(ctx)
+	if len(devices) > 0 {
+		stats.Devices = devices
+		stats.Available = true
+	}
+
+	return stats
+}
+
+// collectFilesystemUsage returns the filesystem half of the disk section.
+func (c *Collector) collectFilesystemUsage(ctx context.Context) *DiskStats {
+	stats := &DiskStats{Available: false}
+
+	// Collect filesystem stats
+	filesystems := c.collectFilesystems(ctx)
+	if len(filesystems) > 0 {
+		stats.Filesystems = filesystems
+		stats.Available = true
+	}
+
+	return stats
+}
+
+func (c *Collector) collectDiskDevices(ctx context.Context) []DiskDevice {
+	data, err := os.ReadFile(filepath.Join(c.procPath, "diskstats"))
+	if err != nil {
+		c.logError(ctx, "disk", readErrorCode(err), fmt.Sprintf("failed to read /proc/diskstats: %v", err))
+		return nil
+	}
+
+	var devices []DiskDevice
+	now := time.Now()
+	bootID := c.bootID()
+	lines := strings.Split(string(data), "\n")
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 14 {
+			continue
+		}
+
+		name := fields[2]
+		// Skip loop, ram, and partition devices
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			continue
+		}
+
+		// Skip partitions - allow whole disks only
+		// Examples: sda, nvme0n1 are OK; sda1, nvme0n1p1 are not
+		if len(name) > 0 && name[len(name)-1] >= '0' && name[len(name)-1] <= '9' {
+			// Special case for NVMe: nvme0n1 is a whole disk (not a partition)
+			// NVMe partitions are nvme0n1p1, nvme0n1p2, etc.
+			isNvmeWholeDisk := strings.HasPrefix(name, "nvme") &&
+				strings.Contains(name, "n") &&
+				!strings.Contains(name, "p")
+			if !isNvmeWholeDisk {
+				continue
+			}
+		}
+
+		readOps, _ := strconv.ParseUint(fields[3], 10, 64)
+		readSectors, _ := strconv.ParseUint(fields[5], 10, 64)
+		writeOps, _ := strconv.ParseUint(fields[7], 10, 64)
+		writeSectors, _ := strconv.ParseUint(fields[9], 10, 64)
+
+		readBytes := readSectors * 512
+		writeBytes := writeSectors * 512
+
+		device := DiskDevice{Name: name}
+
+		var resets [4]bool
+		device.ReadBytesPerSec, resets[0] = c.diskCounters.rate(name+":readBytes", readBytes, now, bootID)
+		device.WriteBytesPerSec, resets[1] = c.diskCounters.rate(name+":writeBytes", writeBytes, now, bootID)
+		device.ReadsPerSec, resets[2] = c.diskCounters.rate(name+":readOps", readOps, now, bootID)
+		device.WritesPerSec, resets[3] = c.diskCounters.rate(name+":writeOps", writeOps, now, bootID)
+		device.CounterReset = resets[0] || resets[1] || resets[2] || resets[3]
+
+		devices = append(devices, device)
+	}
+	c.diskCounters.prune(now)
+
+	return devices
+}
+
+func (c *Collector) collectFilesystems(ctx context.Context) []Filesystem {
+	file, err := os.Open(filepath.Join(c.procPath, "mounts"))
+	if err != nil {
+		c.logError(ctx, "filesystem", readErrorCode(err), fmt.Sprintf("failed to read /proc/mounts: %v", err))
+		return nil
+	}
+	defer file.Close()
+	// First pass: collect mount entries, then their raw statfs values
+	type mountEntry struct {
+		fs        Filesystem
+		total     uint64
+		available uint64
+		free      uint64
+		used      uint64
+	}
+
+	entries := make([]mountEntry, 0)
+	scanner := bufio.NewScanner(file)
+	seen := make(map[string]bool)
+
+	// skip types map
+	skipTypes := map[string]bool{
+		"proc": true, "sysfs": true, "tmpfs": true, "devtmpfs": true, "devpts": true,
+		"cgroup": true, "cgroup2": true, "nsfs": true, "overlay": true, "squashfs": true,
+		"autofs": true, "mqueue": true, "hugetlbfs": true, "debugfs": true, "tracefs": true,
+		"securityfs": true, "pstore": true, "bpf": true, "configfs": true, "fusectl": true,
+		"binfmt_misc": true, "ramfs": true, "efivarfs": true, "rpc_pipefs": true,
+	}
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		device := fields[0]
+		mountPoint := fields[1]
+		fsType := fields[2]
+
+		if skipTypes[fsType] {
+			continue
+		}
+
+		if seen[mountPoint] {
+			continue
+		}
+		seen[mountPoint] = true
+
+		entries = append(entries, mountEntry{fs: Filesystem{
+			MountPoint: mountPoint,
+			Device:     device,
+			FsType:     &fsType,
+		}})
+	}
+
+	mountPoints := make([]string, len(entries))
+	for i := range entries {
+		mountPoints[i] = entries[i].fs.MountPoint
+	}
+	statResults := c.statfsAll(ctx, mountPoints)
+
+	for i := range entries {
+		entry := &entries[i]
+		fs := &entry.fs
+
+		var total, available, free, used uint64
+		if stat, ok := statResults[fs.MountPoint]; ok {
+			total = stat.Blocks * uint64(stat.Bsize)
+			available = stat.Bavail * uint64(stat.Bsize)
+			free = stat.Bfree * uint64(stat.Bsize)
+			used = 0
+			if total > free {
+				used = total - free
+			}
+
+			fs.TotalBytes = &total
+			fs.AvailableBytes = &available
+			fs.UsedBytes = &used
+
+			if total > 0 {
+				usagePercent := (float64(used) / float64(total)) * 100.0
+				fs.UsagePercent = &usagePercent
+			}
+		}
+
+		entry.total, entry.available, entry.free, entry.used = total, available, free, used
+	}
+
+	// Second pass: build final filesystems list with special handling for /mnt/* mounts
+	var filesystems []Filesystem
+	// Determine whether we should apply the TrueNAS /mnt/ special-case.
+	// Can be controlled by env var MENUBAR_TRUENAS_MNT_FIX: "on"/"true"/"1", "off"/"false"/"0", or "auto"/empty.
+	applyTruenasMntFix := false
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("MENUBAR_TRUENAS_MNT_FIX"))) {
+	case "on", "true", "1":
+		applyTruenasMntFix = true
+	case "off", "false", "0":
+		applyTruenasMntFix = false
+	default:
+		applyTruenasMntFix = isLikelyContainer() && isLikelyTrueNAS()
+	}
+	for i := range entries {
+		entry := entries[i]
+		mp := entry.fs.MountPoint
+		if applyTruenasMntFix && strings.HasPrefix(mp, "/mnt/") {
+			// If mountpoint is under /mnt/ and is a child (e.g. /mnt/foo/bar), skip it.
+			rel := strings.TrimPrefix(mp, "/mnt/")
+			if strings.Contains(rel, "/") {
+				// child dataset; skip - its usage will be accumulated into the root
+				continue
+			}
+
+			// For top-level /mnt/<name>, accumulate used from any children
+			var usedSum uint64 = 0
+			for j := range entries {
+				child := entries[j]
+				if child.fs.MountPoint == mp {
+					if child.used != 0 {
+						usedSum += child.used
+					}
+					continue
+				}
+				if strings.HasPrefix(child.fs.MountPoint, mp+"/") {
+					if child.used != 0 {
+						usedSum += child.used
+					}
+				}
+			}
+
+			// If no children contributed used, fall back to the root entry's used value
+			if usedSum == 0 {
+				usedSum = entry.used
+			}
+
+			// Available bytes - prefer the root's available value
+			avail := entry.available
+
+			// Compute total as used + available (handles TrueNAS display quirk)
+			total := usedSum + avail
+
+			fs := entry.fs
+			fs.UsedBytes = &usedSum
+			fs.AvailableBytes = &avail
+			fs.TotalBytes = &total
+			if total > 0 {
+				usagePercent := (float64(usedSum) / float64(total)) * 100.0
+				fs.UsagePercent = &usagePercent
+			}
+
+			filesystems = append(filesystems, fs)
+			continue
+		}
+
+		// Non-/mnt mounts or when fix not applied: keep as-is
+		filesystems = append(filesystems, entry.fs)
+	}
+
+	return filesystems
+}
+
+// statfsAll runs statfs on every mount point concurrently and returns the
+// results that completed within statfsTimeout. Failed calls are reported
+// as errors. A stale NFS or SMB mount can block statfs forever; such calls
+// are abandoned, reported as errors, and not retried until the blocked call
+// returns.
+func (c *Collector) statfsAll(ctx context.Context, mountPoints []string) map[string]syscall.Statfs_t {
+	type result struct {
+		mountPoint string
+		stat       syscall.Statfs_t
+		err        error
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, statfsTimeout)
+	defer cancel()
+
+	// Buffered so abandoned calls can still deliver and exit.
+	results := make(chan result, len(mountPoints))
+	pending := make(map[string]bool)
+
+	c.hungMu.Lock()
+	for _, mp := range mountPoints {
+		if c.hungMounts[mp] {
+			c.logError(ctx, "filesystem", ErrCodeStaleMount, fmt.Sprintf("statfs %s still blocked from a previous collection (stale mount?)", mp))
+			continue
+		}
+		c.hungMounts[mp] = true
+		pending[mp] = true
+
+		go func(mp string) {
+			var stat syscall.Statfs_t
+			err := syscall.Statfs(mp, &stat)
+
+			c.hungMu.Lock()
+			delete(c.hungMounts, mp)
+			c.hungMu.Unlock()
+
+			results <- result{mountPoint: mp, stat: stat, err: err}
+		}(mp)
+	}
+	c.hungMu.Unlock()
+
+	stats := make(map[string]syscall.Statfs_t, len(pending))
+	for len(pending) > 0 {
+		select {
+		case r := <-results:
+			delete(pending, r.mountPoint)
+			switch {
+			case r.err == nil:
+				stats[r.mountPoint] = r.stat
+			case errors.Is(r.err, fs.ErrNotExist):
+				// Unmounted since /proc/mounts was read
+			default:
+				c.logError(ctx, "filesystem", readErrorCode(r.err), fmt.Sprintf("statfs %s failed: %v", r.mountPoint, r.err))
+			}
+		case <-ctx.Done():
+			for mp := range pending {
+				c.logError(ctx, "filesystem", ErrCodeStaleMount, fmt.Sprintf("statfs %s timed out after %s (stale mount?)", mp, statfsTimeout))
+			}
+			return stats
+		}
+	}
+	return stats
+}
+
+// isLikelyContainer returns true when the process appears to be running inside a container.
+func isLikelyContainer() bool {
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return true
+	}
+	if data, err := os.ReadFile("/proc/1/cgroup"); err == nil {
+		s := string(data)
+		if strings.Contains(s, "docker") || strings.Contains(s, "containerd") || strings.Contains(s, "kubepods") {
+			return true
+		}
+	}
+	return false
+}
+
+// isLikelyTrueNAS does a best-effort check for TrueNAS SCALE environment.
+func isLikelyTrueNAS() bool {
+	if _, err := os.Stat("/etc/truenas-release"); err == nil {
+		return true
+	}
+	if data, err := os.ReadFile("/etc/os-release"); err == nil {
+		s := strings.ToLower(string(data))
+		if strings.Contains(s, "truenas") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package stats
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Error codes. They are stable identifiers clients can match on; the
+// message carries the details.
+const (
+	ErrCodeReadFailed       = "read_failed"
+	ErrCodePermissionDenied = "permission_denied"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeTimeout          = "timeout"
+	ErrCodeStillRunning     = "still_running"
+	ErrCodeStaleMount       = "stale_mount"
+	ErrCodeProcessMissing   = "process_missing"
+)
+
+// maxRecoveredErrors bounds how many cleared errors are remembered.
+const maxRecoveredErrors = 50
+
+// AgentError is a collection problem. An error is active from the first
+// source run that reports it until the first run that doesn't; repeated
+// reports only bump LastSeen and Count. Times are unix milliseconds.
+type AgentError struct {
+	Source      string `json:"source"`
+	Component   string `json:"component"`
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	FirstSeen   int64  `json:"firstSeen"`
+	LastSeen    int64  `json:"lastSeen"`
+	Count       int    `json:"count"`
+	RecoveredAt int64  `json:"recoveredAt,omitempty"`
+}
+
+// String formats the error the way the flat Errors list has always shown
+// it.
+func (e AgentError) String() string {
+	return e.Component + ": " + e.Message
+}
+
+func (e AgentError) key() string {
+	return e.Source + "\x00" + e.Component + "\x00" + e.Code + "\x00" + e.Message
+}
+
+// ErrorReport lists the active errors and the most recently cleared ones.
+type ErrorReport struct {
+	Active    []AgentError `json:"active"`
+	Recovered []AgentError `json:"recovered"`
+}
+
+// readErrorCode classifies a failed read.
+func readErrorCode(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return ErrCodePermissionDenied
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrCodeNotFound
+	default:
+		return ErrCodeReadFailed
+	}
+}
+
+// errorTracker keeps the active errors of every source and logs when an
+// error first appears and when it clears.
+type errorTracker struct {
+	log       *slog.Logger
+	mu        sync.Mutex
+	active    map[string]*AgentError
+	recovered []AgentError // Oldest first
+}
+
+func newErrorTracker(log *slog.Logger) *errorTracker {
+	return &errorTracker{log: log, active: make(map[string]*AgentError)}
+}
+
+// update records the errors of one run of source. Errors the source
+// reported before but not in this run have recovered. A partial run (one
+// skipped because the previous run is still blocked) can't tell whether
+// earlier errors cleared, so they stay active.
+func (t *errorTracker) update(source string, errs []AgentError, now time.Time, partial bool) {
+	nowMs := now.UnixMilli()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seen := make(map[string]bool, len(errs))
+	for _, e := range errs {
+		e.Source = source
+		key := e.key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if existing, ok := t.active[key]; ok {
+			existing.LastSeen = nowMs
+			existing.Count++
+			continue
+		}
+		added := e
+		added.FirstSeen, added.LastSeen, added.Count = nowMs, nowMs, 1
+		t.active[key] = &added
+		t.log.Warn("collection error", "source", added.Source, "collector", added.Component, "code", added.Code, "error", added.Message)
+	}
+
+	if partial {
+		return
+	}
+	for key, e := range t.active {
+		if e.Source != source || seen[key] {
+			continue
+		}
+		delete(t.active, key)
+		e.RecoveredAt = nowMs
+		t.log.Info("collection error recovered", "source", e.Source, "collector", e.Component, "code", e.Code,
+			"error", e.Message, "after", (time.Duration(nowMs-e.FirstSeen) * time.Millisecond).String(), "occurrences", e.Count)
+
+		t.recovered = append(t.recovered, *e)
+		if len(t.recovered) > maxRecoveredErrors {
+			t.recovered = t.recovered[len(t.recovered)-maxRecoveredErrors:]
+		}
+	}
+}
+
+// forSource returns the active errors of source, oldest first.
+func (t *errorTracker) forSource(source string) []AgentError {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var errs []AgentError
+	for _, e := range t.active {
+		if e.Source == source {
+			errs = append(errs, *e)
+		}
+	}
+	sortErrors(errs)
+	return errs
+}
+
+// report returns every active error, oldest first, and the recovered
+// errors, most recent first.
+func (t *errorTracker) report() ErrorReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := ErrorReport{
+		Active:    make([]AgentError, 0, len(t.active)),
+		Recovered: make([]AgentError, 0, len(t.recovered)),
+	}
+	for _, e := range t.active {
+		report.Active = append(report.Active, *e)
+	}
+	sortErrors(report.Active)
+	for i := len(t.recovered) - 1; i >= 0; i-- {
+		report.Recovered = append(report.Recovered, t.recovered[i])
+	}
+	return report
+}
+
+func sortErrors(errs []AgentError) {
+	sort.Slice(errs, func(i, j int) bool {
+		if errs[i].FirstSeen != errs[j].FirstSeen {
+			return errs[i].FirstSeen < errs[j].FirstSeen
+		}
+		return errs[i].key() < errs[j].key()
+	})
+}
+
+// Errors returns the active and recently recovered collection errors.
+func (c *Collector) Errors() ErrorReport {
+	return c.errors.report()
+}
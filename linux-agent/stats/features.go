@@ -0,0 +1,30 @@
+package stats
+
+import "context"
+
+// collectFeatures reports which optional features the host has. The feature
+// probes are cheap, so they run every time and hot-plugged devices or newly
+// installed tools show up without a restart; the cached capability report
+// is refreshed along the way.
+func (c *Collector) collectFeatures(ctx context.Context) *Features {
+	probed := c.probeFeatures()
+
+	c.capMu.Lock()
+	if c.capabilities != nil {
+		report := *c.capabilities
+		report.Features = probed
+		c.capabilities = &report
+	}
+	c.capMu.Unlock()
+
+	available := func(feature string) *bool {
+		ok := probed[feature].Available
+		return &ok
+	}
+	return &Features{
+		SmartAvailable:   available(FeatureSmart),
+		NvmeAvailable:    available(FeatureNvme),
+		ThermalAvailable: available(FeatureThermal),
+		GpuAvailable:     available(FeatureGpu),
+	}
+}
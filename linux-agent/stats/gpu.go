@@ -0,0 +1,9 @@
+package stats
+
+import "context"
+
+func (c *Collector) collectGPU(ctx context.Context) *GPUStats {
+	// GPU monitoring requires vendor-specific tools (nvidia-smi, rocm-smi, etc.)
+	// Not available without external dependencies
+	return &GPUStats{Available: false}
+}
@@ -0,0 +1,138 @@
+package stats
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxGroups bounds each list in GroupStats.
+const maxGroups = 50
+
+// systemdUnit returns the unit and slice a cgroup path belongs to: the
+// deepest .service or .scope component and the deepest .slice component.
+// Paths outside systemd's layout (plain Docker, the root cgroup) have no
+// unit and are their own slice.
+func systemdUnit(cgroup string) (unit, slice string) {
+	for _, part := range strings.Split(strings.Trim(cgroup, "/"), "/") {
+		switch {
+		case strings.HasSuffix(part, ".service"), strings.HasSuffix(part, ".scope"):
+			unit = part
+		case strings.HasSuffix(part, ".slice"):
+			slice = part
+		}
+	}
+	if slice == "" && unit == "" {
+		slice = cgroup
+	}
+	return unit, slice
+}
+
+// treeRoots maps each PID to the root of its process tree: the ancestor
+// whose parent is init (PID 1) or the kernel (PID 0). Daemons that fork
+// workers, such as smbd or nginx, and all kernel threads (under kthreadd)
+// each form one tree. Walks stop at parents that aren't visible, as in a
+// container's PID namespace.
+func treeRoots(processes []ProcessInfo) map[int]int {
+	parent := make(map[int]int, len(processes))
+	for _, p := range processes {
+		parent[p.PID] = p.PPID
+	}
+	roots := make(map[int]int, len(processes))
+	var root func(pid int, depth int) int
+	root = func(pid int, depth int) int {
+		if r, ok := roots[pid]; ok {
+			return r
+		}
+		ppid, ok := parent[pid]
+		r := pid
+		if ok && ppid > 1 && depth < len(parent) {
+			if _, visible := parent[ppid]; visible {
+				r = root(ppid, depth+1)
+			}
+		}
+		roots[pid] = r
+		return r
+	}
+	for _, p := range processes {
+		root(p.PID, 0)
+	}
+	return roots
+}
+
+// groupProcesses rolls processes up by owner, name, systemd unit and
+// slice, and process tree.
+func groupProcesses(processes []ProcessInfo) *GroupStats {
+	stats := &GroupStats{Available: len(processes) > 0}
+
+	names := make(map[int]string, len(processes))
+	for _, p := range processes {
+		names[p.PID] = p.Name
+	}
+	roots := treeRoots(processes)
+
+	users := make(map[string]*ProcessGroup)
+	byName := make(map[string]*ProcessGroup)
+	units := make(map[string]*ProcessGroup)
+	slices := make(map[string]*ProcessGroup)
+	trees := make(map[string]*ProcessGroup)
+	add := func(groups map[string]*ProcessGroup, key string, p ProcessInfo) {
+		if key == "" {
+			return
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &ProcessGroup{Key: key}
+			groups[key] = g
+		}
+		g.Processes++
+		g.CPUPercent += valueOr(p.CPUPercent, 0)
+		g.RSSBytes += p.RSSBytes
+		g.ReadBytesPerSec += valueOr(p.ReadBytesPerSec, 0)
+		g.WriteBytesPerSec += valueOr(p.WriteBytesPerSec, 0)
+	}
+
+	for _, p := range processes {
+		user := p.User
+		if user == "" && p.UID != nil {
+			user = strconv.Itoa(*p.UID)
+		}
+		add(users, user, p)
+		add(byName, p.Name, p)
+		if p.Cgroup != "" {
+			unit, slice := systemdUnit(p.Cgroup)
+			add(units, unit, p)
+			add(slices, slice, p)
+		}
+		root := roots[p.PID]
+		add(trees, names[root]+" ("+strconv.Itoa(root)+")", p)
+	}
+
+	stats.Users = topGroups(users)
+	stats.Names = topGroups(byName)
+	stats.Units = topGroups(units)
+	stats.Slices = topGroups(slices)
+	stats.Trees = topGroups(trees)
+	return stats
+}
+
+// topGroups returns the busiest maxGroups groups by CPU, then memory.
+func topGroups(groups map[string]*ProcessGroup) []ProcessGroup {
+	list := make([]ProcessGroup, 0, len(groups))
+	for _, g := range groups {
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CPUPercent != list[j].CPUPercent {
+			return list[i].CPUPercent > list[j].CPUPercent
+		}
+		if list[i].RSSBytes != list[j].RSSBytes {
+			return list[i].RSSBytes > list[j].RSSBytes
+		}
+		return list[i].Key < list[j].Key
+	})
+	if len(list) > maxGroups {
+		list = list[:maxGroups]
+	}
+	return list
+}
@@ -0,0 +1,150 @@
+package stats
+
+import "time"
+
+// Health states, for the agent as a whole and for each collector.
+const (
+	HealthOK       = "ok"       // Fresh data, no errors
+	HealthDegraded = "degraded" // Fresh data, but errors or some collectors failing
+	HealthFailed   = "failed"   // No usable data
+	HealthStarting = "starting" // No sample yet, still within the first interval
+	HealthDisabled = "disabled" // Collector turned off by configuration
+)
+
+// minSamplerStall is the shortest time without a scheduler tick after which
+// the sampler counts as stalled; it allows for GC pauses and a busy host at
+// short intervals.
+const minSamplerStall = 5 * time.Second
+
+// Health summarizes whether the agent is producing data.
+type Health struct {
+	Status     string            `json:"status"`
+	Sampler    SamplerHealth     `json:"sampler"`
+	Collectors []CollectorHealth `json:"collectors"`
+}
+
+// SamplerHealth reports whether the background sampler is ticking.
+type SamplerHealth struct {
+	Running       bool   `json:"running"`
+	LastTickAgeMs *int64 `json:"lastTickAgeMs,omitempty"`
+	Idle          bool   `json:"idle"`
+}
+
+// CollectorHealth is the state of one collector. A collector is failed when
+// its last successful sample is older than twice its interval plus the
+// collector timeout, or when its data is unavailable because of errors.
+type CollectorHealth struct {
+	Name             string `json:"name"`
+	Status           string `json:"status"`
+	LastSuccessAgeMs *int64 `json:"lastSuccessAgeMs,omitempty"`
+	ActiveErrors     int    `json:"activeErrors"`
+}
+
+// Health reports the state of the sampler and every registered collector.
+func (c *Collector) Health() Health {
+	now := time.Now()
+	health := Health{Sampler: c.samplerHealth(now)}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	enabled, failed, starting, degraded := 0, 0, 0, 0
+	for _, source := range c.registry.Sources() {
+		ch := CollectorHealth{Name: source.Name(), Status: HealthOK}
+		if !c.registry.IsEnabled(source) {
+			ch.Status = HealthDisabled
+			health.Collectors = append(health.Collectors, ch)
+			continue
+		}
+		enabled++
+		ch.ActiveErrors = len(c.errors.forSource(source.Name()))
+
+		staleAfter := 2*c.effectiveInterval(source, now) + c.timeout
+		result, ok := c.results[source.Name()]
+		switch {
+		case !ok:
+			started := c.samplerStarted.Load()
+			if started != 0 && now.Sub(time.Unix(0, started)) < staleAfter {
+				ch.Status = HealthStarting
+			} else {
+				ch.Status = HealthFailed
+			}
+		case now.Sub(result.collectedAt) > staleAfter:
+			ch.Status = HealthFailed
+		case ch.ActiveErrors > 0 && !result.available:
+			ch.Status = HealthFailed
+		case ch.ActiveErrors > 0:
+			ch.Status = HealthDegraded
+		}
+		if ok {
+			age := now.Sub(result.collectedAt).Milliseconds()
+			ch.LastSuccessAgeMs = &age
+		}
+
+		switch ch.Status {
+		case HealthFailed:
+			failed++
+		case HealthStarting:
+			starting++
+		case HealthDegraded:
+			degraded++
+		}
+		health.Collectors = append(health.Collectors, ch)
+	}
+
+	switch {
+	case !health.Sampler.Running, enabled > 0 && failed == enabled:
+		health.Status = HealthFailed
+	case starting > 0:
+		health.Status = HealthStarting
+	case failed > 0 || degraded > 0:
+		health.Status = HealthDegraded
+	default:
+		health.Status = HealthOK
+	}
+	return health
+}
+
+func (c *Collector) samplerHealth(now time.Time) SamplerHealth {
+	sampler := SamplerHealth{Idle: c.Idle()}
+	tick := c.samplerTick.Load()
+	if tick == 0 {
+		// Started but not yet ticked counts as running
+		sampler.Running = c.samplerStarted.Load() != 0
+		return sampler
+	}
+
+	age := now.Sub(time.Unix(0, tick))
+	ageMs := age.Milliseconds()
+	sampler.LastTickAgeMs = &ageMs
+
+	// The sampler ticks when the next source is due: every interval at
+	// most, every idle interval at most while idle
+	tickEvery := c.interval
+	if sampler.Idle {
+		tickEvery = c.idleInterval
+	}
+	stallAfter := 3 * tickEvery
+	if stallAfter < minSamplerStall {
+		stallAfter = minSamplerStall
+	}
+	sampler.Running = age <= stallAfter
+	return sampler
+}
+
+// effectiveInterval is how often source is currently expected to produce a
+// sample: its own interval, or the idle interval while idle and for one
+// idle interval after waking, when idle-era samples are still being served.
+func (c *Collector) effectiveInterval(source Source, now time.Time) time.Duration {
+	interval := c.registry.IntervalOf(source)
+	if c.idleAfter <= 0 || interval >= c.idleInterval {
+		return interval
+	}
+
+	c.activityMu.Lock()
+	defer c.activityMu.Unlock()
+	if c.idle || now.Sub(c.wokeAt) < c.idleInterval {
+		return c.idleInterval
+	}
+	return interval
+}
@@ -0,0 +1,135 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	type state struct {
+		started   time.Duration // Sampler started this long ago; 0 if not
+		tick      time.Duration // Last sampler tick this long ago; 0 if none
+		result    time.Duration // Counter collected this long ago; 0 if never
+		noData    bool          // The result has no available data
+		hasErrors bool
+	}
+	tests := []struct {
+		name            string
+		state           state
+		wantStatus      string
+		wantSampler     bool
+		wantCollector   string
+		wantAgeReported bool
+	}{
+		{
+			name:          "sampler never started",
+			wantStatus:    HealthFailed,
+			wantCollector: HealthFailed,
+		},
+		{
+			name:          "first sample pending",
+			state:         state{started: 100 * time.Millisecond},
+			wantStatus:    HealthStarting,
+			wantSampler:   true,
+			wantCollector: HealthStarting,
+		},
+		{
+			name:          "first sample overdue",
+			state:         state{started: time.Minute, tick: 100 * time.Millisecond},
+			wantStatus:    HealthFailed,
+			wantSampler:   true,
+			wantCollector: HealthFailed,
+		},
+		{
+			name:            "fresh",
+			state:           state{started: time.Minute, tick: 100 * time.Millisecond, result: 500 * time.Millisecond},
+			wantStatus:      HealthOK,
+			wantSampler:     true,
+			wantCollector:   HealthOK,
+			wantAgeReported: true,
+		},
+		{
+			name:            "stale",
+			state:           state{started: time.Hour, tick: 100 * time.Millisecond, result: time.Minute},
+			wantStatus:      HealthFailed,
+			wantSampler:     true,
+			wantCollector:   HealthFailed,
+			wantAgeReported: true,
+		},
+		{
+			name:            "errors with data",
+			state:           state{started: time.Minute, tick: 100 * time.Millisecond, result: 500 * time.Millisecond, hasErrors: true},
+			wantStatus:      HealthDegraded,
+			wantSampler:     true,
+			wantCollector:   HealthDegraded,
+			wantAgeReported: true,
+		},
+		{
+			name:            "errors without data",
+			state:           state{started: time.Minute, tick: 100 * time.Millisecond, result: 500 * time.Millisecond, noData: true, hasErrors: true},
+			wantStatus:      HealthFailed,
+			wantSampler:     true,
+			wantCollector:   HealthFailed,
+			wantAgeReported: true,
+		},
+		{
+			name:            "sampler stalled",
+			state:           state{started: time.Hour, tick: time.Minute, result: 500 * time.Millisecond},
+			wantStatus:      HealthFailed,
+			wantSampler:     false,
+			wantCollector:   HealthOK,
+			wantAgeReported: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := countingCollector(t, Options{Interval: time.Second})
+			off := NewSource("off", time.Second, func(ctx context.Context, s *RemoteLinuxStats) {})
+			if err := c.registry.Register(off); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.registry.SetEnabled("off", false); err != nil {
+				t.Fatal(err)
+			}
+
+			now := time.Now()
+			if tt.state.started > 0 {
+				c.samplerStarted.Store(now.Add(-tt.state.started).UnixNano())
+			}
+			if tt.state.tick > 0 {
+				c.samplerTick.Store(now.Add(-tt.state.tick).UnixNano())
+			}
+			if tt.state.result > 0 {
+				c.results["counter"] = &sourceResult{collectedAt: now.Add(-tt.state.result), available: !tt.state.noData}
+			}
+			if tt.state.hasErrors {
+				c.errors.update("counter", []AgentError{{Component: "counter", Code: ErrCodeReadFailed, Message: "read failed"}}, now, false)
+			}
+
+			health := c.Health()
+			if health.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", health.Status, tt.wantStatus)
+			}
+			if health.Sampler.Running != tt.wantSampler {
+				t.Errorf("sampler running = %v, want %v", health.Sampler.Running, tt.wantSampler)
+			}
+
+			statuses := make(map[string]CollectorHealth)
+			for _, ch := range health.Collectors {
+				statuses[ch.Name] = ch
+			}
+			counter := statuses["counter"]
+			if counter.Status != tt.wantCollector {
+				t.Errorf("counter status = %s, want %s", counter.Status, tt.wantCollector)
+			}
+			if (counter.LastSuccessAgeMs != nil) != tt.wantAgeReported {
+				t.Errorf("counter lastSuccessAgeMs = %v, want reported: %v", counter.LastSuccessAgeMs, tt.wantAgeReported)
+			}
+			if statuses["off"].Status != HealthDisabled {
+				t.Errorf("disabled source status = %s, want %s", statuses["off"].Status, HealthDisabled)
+			}
+		})
+	}
+}
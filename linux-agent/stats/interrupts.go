@@ -0,0 +1,265 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultIRQImbalancePercent is the share of a NIC's interrupts one CPU
+// may handle before the NIC is flagged as imbalanced.
+const DefaultIRQImbalancePercent = 80.0
+
+// minNICInterruptRate is the interrupt rate below which a NIC is never
+// flagged: an idle link with all of its few interrupts on one CPU is fine.
+const minNICInterruptRate = 100.0
+
+// interruptTable is /proc/interrupts or /proc/softirqs: one column per CPU,
+// one row per interrupt source.
+type interruptTable struct {
+	cpus []int
+	rows []interruptRow
+}
+
+type interruptRow struct {
+	name   string   // IRQ number, or a name such as LOC or NET_RX
+	counts []uint64 // One per column; rows such as ERR have fewer
+	desc   string   // Remainder of the line (chip, type, devices)
+}
+
+// parseInterruptTable parses the CPU header and the rows that follow it.
+func parseInterruptTable(data string) (interruptTable, error) {
+	lines := strings.Split(data, "\n")
+	var table interruptTable
+	for _, column := range strings.Fields(lines[0]) {
+		id, err := strconv.Atoi(strings.TrimPrefix(column, "CPU"))
+		if err != nil {
+			return table, fmt.Errorf("unexpected header column %q", column)
+		}
+		table.cpus = append(table.cpus, id)
+	}
+	if len(table.cpus) == 0 {
+		return table, fmt.Errorf("no CPU columns in header")
+	}
+
+	for _, line := range lines[1:] {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		row := interruptRow{name: strings.TrimSpace(name)}
+		i := 0
+		for ; i < len(fields) && i < len(table.cpus); i++ {
+			count, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				break
+			}
+			row.counts = append(row.counts, count)
+		}
+		row.desc = strings.Join(fields[i:], " ")
+		table.rows = append(table.rows, row)
+	}
+	return table, nil
+}
+
+// irqDevice returns the device names of a numbered IRQ from sysfs, falling
+// back to the last word of its /proc/interrupts description.
+func (c *Collector) irqDevice(irq, desc string) string {
+	if data, err := os.ReadFile(filepath.Join(c.sysPath, "kernel/irq", irq, "actions")); err == nil {
+		if actions := strings.TrimSpace(string(data)); actions != "" {
+			return actions
+		}
+	}
+	fields := strings.Fields(desc)
+	if len(fields) < 3 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// nicIRQs maps IRQ numbers to the network interface they belong to, from
+// the MSI vectors of each interface's PCI device. Interfaces without MSI
+// (virtio without MSI-X, USB) are matched on IRQ device names instead.
+func (c *Collector) nicIRQs() (byIRQ map[string]string, ifaces []string) {
+	byIRQ = make(map[string]string)
+	entries, err := os.ReadDir(filepath.Join(c.sysPath, "class/net"))
+	if err != nil {
+		return byIRQ, nil
+	}
+	for _, entry := range entries {
+		iface := entry.Name()
+		if iface == "lo" {
+			continue
+		}
+		// Virtual interfaces have no device link
+		if _, err := os.Stat(filepath.Join(c.sysPath, "class/net", iface, "device")); err != nil {
+			continue
+		}
+		ifaces = append(ifaces, iface)
+		vectors, _ := os.ReadDir(filepath.Join(c.sysPath, "class/net", iface, "device/msi_irqs"))
+		for _, vector := range vectors {
+			byIRQ[vector.Name()] = iface
+		}
+	}
+	return byIRQ, ifaces
+}
+
+// nicForDevice matches an IRQ device name such as "enp3s0-rx-0" or
+// "eth0-TxRx-3" against the physical interfaces.
+func nicForDevice(device string, ifaces []string) string {
+	for _, iface := range ifaces {
+		if device == iface || strings.HasPrefix(device, iface+"-") {
+			return iface
+		}
+	}
+	return ""
+}
+
+// tableRates turns one row's counts into per-CPU rates keyed by
+// prefix/row/cpu. The row is skipped (ok false) until every cell has a
+// previous sample.
+func (c *Collector) tableRates(prefix string, table interruptTable, row interruptRow, now time.Time, bootID string) (perCPU []float64, total float64, reset, ok bool) {
+	if len(row.counts) != len(table.cpus) {
+		return nil, 0, false, false
+	}
+	ok = true
+	perCPU = make([]float64, len(row.counts))
+	for i, count := range row.counts {
+		key := prefix + "/" + row.name + "/" + strconv.Itoa(table.cpus[i])
+		rate, wasReset := c.irqCounters.rate(key, count, now, bootID)
+		if wasReset {
+			reset = true
+		}
+		if rate == nil {
+			ok = false
+			continue
+		}
+		perCPU[i] = *rate
+		total += *rate
+	}
+	return perCPU, total, reset, ok
+}
+
+// collectInterrupts reads /proc/interrupts and /proc/softirqs and reports
+// per-CPU rates for every interrupt source that fired since the previous
+// sample, plus how each NIC's interrupts are spread across CPUs.
+func (c *Collector) collectInterrupts(ctx context.Context) *InterruptStats {
+	stats := &InterruptStats{Available: false}
+	now := time.Now()
+	bootID := c.bootID()
+
+	path := filepath.Join(c.procPath, "interrupts")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.logError(ctx, "interrupts", readErrorCode(err), fmt.Sprintf("failed to read %s: %v", path, err))
+		return stats
+	}
+	table, err := parseInterruptTable(string(data))
+	if err != nil {
+		c.logError(ctx, "interrupts", ErrCodeReadFailed, fmt.Sprintf("failed to parse %s: %v", path, err))
+		return stats
+	}
+	stats.CPUs = table.cpus
+	stats.Available = true
+
+	type nicLoad struct {
+		summary NICInterruptSummary
+		perCPU  []float64
+	}
+	byIRQ, ifaces := c.nicIRQs()
+	nics := make(map[string]*nicLoad)
+	for _, row := range table.rows {
+		perCPU, total, reset, ok := c.tableRates("irq", table, row, now, bootID)
+		if reset {
+			stats.CounterReset = true
+		}
+		if !ok || total == 0 {
+			continue
+		}
+
+		irq := IRQStats{IRQ: row.name, TotalPerSec: total, PerCPUPerSec: perCPU}
+		if _, err := strconv.Atoi(row.name); err == nil {
+			irq.Device = c.irqDevice(row.name, row.desc)
+			irq.Interface = byIRQ[row.name]
+			if irq.Interface == "" {
+				irq.Interface = nicForDevice(irq.Device, ifaces)
+			}
+		} else {
+			irq.Device = row.desc
+		}
+		stats.IRQs = append(stats.IRQs, irq)
+
+		if irq.Interface == "" {
+			continue
+		}
+		nic, ok := nics[irq.Interface]
+		if !ok {
+			nic = &nicLoad{summary: NICInterruptSummary{Interface: irq.Interface}, perCPU: make([]float64, len(table.cpus))}
+			nics[irq.Interface] = nic
+		}
+		nic.summary.IRQs++
+		nic.summary.TotalPerSec += total
+		for i, rate := range perCPU {
+			nic.perCPU[i] += rate
+		}
+	}
+	sort.Slice(stats.IRQs, func(i, j int) bool { return stats.IRQs[i].TotalPerSec > stats.IRQs[j].TotalPerSec })
+
+	for _, nic := range nics {
+		top := 0
+		for i, rate := range nic.perCPU {
+			if rate > nic.perCPU[top] {
+				top = i
+			}
+		}
+		summary := nic.summary
+		summary.TopCPU = table.cpus[top]
+		summary.TopCPUPercent = nic.perCPU[top] / summary.TotalPerSec * 100.0
+		summary.Imbalanced = len(table.cpus) > 1 && summary.TotalPerSec >= minNICInterruptRate &&
+			summary.TopCPUPercent > c.irqImbalancePercent
+		if summary.Imbalanced {
+			stats.Imbalanced = true
+		}
+		stats.NICs = append(stats.NICs, summary)
+	}
+	sort.Slice(stats.NICs, func(i, j int) bool { return stats.NICs[i].Interface < stats.NICs[j].Interface })
+
+	// Softirqs are optional: without them the hard IRQs are still useful.
+	// /proc/softirqs has a column for every possible CPU, /proc/interrupts
+	// only for online ones, so the rates are lined up with stats.CPUs.
+	column := make(map[int]int, len(table.cpus))
+	for i, cpu := range table.cpus {
+		column[cpu] = i
+	}
+	path = filepath.Join(c.procPath, "softirqs")
+	if data, err := os.ReadFile(path); err == nil {
+		if softirqs, err := parseInterruptTable(string(data)); err == nil {
+			for _, row := range softirqs.rows {
+				rates, _, reset, ok := c.tableRates("softirq", softirqs, row, now, bootID)
+				if reset {
+					stats.CounterReset = true
+				}
+				if !ok {
+					continue
+				}
+				softirq := SoftirqStats{Name: row.name, PerCPUPerSec: make([]float64, len(table.cpus))}
+				for i, rate := range rates {
+					if j, online := column[softirqs.cpus[i]]; online {
+						softirq.PerCPUPerSec[j] = rate
+						softirq.TotalPerSec += rate
+					}
+				}
+				stats.Softirqs = append(stats.Softirqs, softirq)
+			}
+		}
+	}
+
+	c.irqCounters.prune(now)
+	return stats
+}
@@ -0,0 +1,97 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInterruptTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    interruptTable
+		wantErr bool
+	}{
+		{
+			name: "numbered and named rows",
+			data: `           CPU0       CPU1
+  0:         44          0   IO-APIC   2-edge      timer
+ 24:     120004       3312   PCI-MSI 1572864-edge      eth0-TxRx-0
+NMI:          0          0   Non-maskable interrupts
+LOC:    9123456    8123456   Local timer interrupts
+`,
+			want: interruptTable{
+				cpus: []int{0, 1},
+				rows: []interruptRow{
+					{name: "0", counts: []uint64{44, 0}, desc: "IO-APIC 2-edge timer"},
+					{name: "24", counts: []uint64{120004, 3312}, desc: "PCI-MSI 1572864-edge eth0-TxRx-0"},
+					{name: "NMI", counts: []uint64{0, 0}, desc: "Non-maskable interrupts"},
+					{name: "LOC", counts: []uint64{9123456, 8123456}, desc: "Local timer interrupts"},
+				},
+			},
+		},
+		{
+			name: "rows with a single count",
+			data: `           CPU0       CPU1
+ERR:          3
+MIS:          0
+`,
+			want: interruptTable{
+				cpus: []int{0, 1},
+				rows: []interruptRow{
+					{name: "ERR", counts: []uint64{3}, desc: ""},
+					{name: "MIS", counts: []uint64{0}, desc: ""},
+				},
+			},
+		},
+		{
+			name: "offline CPUs leave gaps in the header",
+			data: `           CPU0       CPU2
+ 30:          5          7   PCI-MSI 65536-edge      nvme0q0
+`,
+			want: interruptTable{
+				cpus: []int{0, 2},
+				rows: []interruptRow{
+					{name: "30", counts: []uint64{5, 7}, desc: "PCI-MSI 65536-edge nvme0q0"},
+				},
+			},
+		},
+		{
+			name: "description starting with a number stays in desc",
+			data: `           CPU0
+  9:          1   IO-APIC   9-fasteoi   acpi
+`,
+			want: interruptTable{
+				cpus: []int{0},
+				rows: []interruptRow{
+					{name: "9", counts: []uint64{1}, desc: "IO-APIC 9-fasteoi acpi"},
+				},
+			},
+		},
+		{
+			name:    "bad header",
+			data:    "  0:  44  IO-APIC\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			data:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInterruptTable(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInterruptTable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseInterruptTable() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func (c *Collector) collectMemory(ctx context.Context) *MemoryStats {
+	stats := &MemoryStats{Available: false}
+
+	data, err := os.ReadFile(filepath.Join(c.procPath, "meminfo"))
+	if err != nil {
+		c.logError(ctx, "memory", readErrorCode(err), fmt.Sprintf("failed to read /proc/meminfo: %v", err))
+		return stats
+	}
+
+	memInfo := make(map[string]uint64)
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		key := strings.TrimSuffix(fields[0], ":")
+		val, _ := strconv.ParseUint(fields[1], 10, 64)
+		// Convert from kB to bytes
+		memInfo[key] = val * 1024
+	}
+
+	if total, ok := memInfo["MemTotal"]; ok {
+		stats.TotalBytes = &total
+		stats.Available = true
+	}
+	if avail, ok := memInfo["MemAvailable"]; ok {
+		stats.AvailableBytes = &avail
+	}
+	if buffers, ok := memInfo["Buffers"]; ok {
+		stats.BuffersBytes = &buffers
+	}
+	if cached, ok := memInfo["Cached"]; ok {
+		stats.CachedBytes = &cached
+	}
+	if swapTotal, ok := memInfo["SwapTotal"]; ok {
+		stats.SwapTotalBytes = &swapTotal
+	}
+	if swapFree, ok := memInfo["SwapFree"]; ok {
+		if swapTotal, ok := memInfo["SwapTotal"]; ok {
+			swapUsed := swapTotal - swapFree
+			stats.SwapUsedBytes = &swapUsed
+		}
+	}
+	if swapCached, ok := memInfo["SwapCached"]; ok {
+		stats.SwapCachedBytes = &swapCached
+	}
+
+	// Calculate used bytes
+	if stats.TotalBytes != nil && stats.AvailableBytes != nil {
+		used := *stats.TotalBytes - *stats.AvailableBytes
+		stats.UsedBytes = &used
+
+		effective := used
+		if stats.ZFSARC = c.collectARC(ctx); stats.ZFSARC != nil {
+			effective -= min(stats.ZFSARC.ReclaimableBytes, used)
+		}
+		stats.EffectiveUsedBytes = &effective
+	}
+
+	// PSI (Pressure Stall Information)
+	c.collectPSI(stats)
+
+	return stats
+}
+
+func (c *Collector) collectPSI(stats *MemoryStats) {
+	data, err := os.ReadFile(filepath.Join(c.procPath, "pressure/memory"))
+	if err != nil {
+		return // PSI is optional
+	}
+
+	if some, _ := parsePressure(string(data)); some != nil {
+		stats.PsiMemAvg10 = &some.Avg10
+		stats.PsiMemAvg60 = &some.Avg60
+		stats.PsiMemAvg300 = &some.Avg300
+	}
+}
@@ -0,0 +1,278 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (c *Collector) collectNetwork(ctx context.Context) *NetworkStats {
+	stats := &NetworkStats{Available: false}
+
+	data, err := os.ReadFile(filepath.Join(c.procPath, "net/dev"))
+	if err != nil {
+		c.logError(ctx, "network", readErrorCode(err), fmt.Sprintf("failed to read /proc/net/dev: %v", err))
+		return stats
+	}
+
+	var interfaces []NetworkInterface
+	now := time.Now()
+	bootID := c.bootID()
+	lines := strings.Split(string(data), "\n")
+
+	for i, line := range lines {
+		if i < 2 { // Skip header lines
+			continue
+		}
+
+		parts := strings.Split(line, ":")
+		if len(parts) != 2 {
+			continue
+		}
+
+		name := strings.TrimSpace(parts[0])
+		if name == "lo" { // Skip loopback
+			continue
+		}
+
+		fields := strings.Fields(parts[1])
+		if len(fields) < 16 {
+			continue
+		}
+
+		rxBytes, _ := strconv.ParseUint(fields[0], 10, 64)
+		txBytes, _ := strconv.ParseUint(fields[8], 10, 64)
+
+		iface := NetworkInterface{Name: name}
+
+		var rxReset, txReset bool
+		iface.RxBytesPerSec, rxReset = c.netCounters.rate(name+":rx", rxBytes, now, bootID)
+		iface.TxBytesPerSec, txReset = c.netCounters.rate(name+":tx", txBytes, now, bootID)
+		iface.CounterReset = rxReset || txReset
+
+		// Try to get IP and MAC addresses
+		c.enrichNetworkInterface(ctx, &iface)
+
+		interfaces = append(interfaces, iface)
+		stats.Available = true
+	}
+
+	c.netCounters.prune(now)
+
+	if len(interfaces) > 0 {
+		stats.Interfaces = interfaces
+	}
+
+	return stats
+}
+
+// collectExternalIP returns the network section's external address. It
+// makes outbound HTTP calls, so it runs far less often than the interfaces.
+func (c *Collector) collectExternalIP(ctx context.Context) *NetworkStats {
+	stats := &NetworkStats{}
+
+	// Try to get external IPv4 address (best effort, non-blocking)
+	if externalIP := c.getExternalIPv4(ctx); externalIP != "" {
+		stats.ExternalIPv4 = &externalIP
+	}
+
+	return stats
+}
+
+func (c *Collector) enrichNetworkInterface(ctx context.Context, iface *NetworkInterface) {
+	// Try to read MAC address
+	macPath := filepath.Join(c.sysPath, "class/net", iface.Name, "address")
+	if data, err := os.ReadFile(macPath); err == nil {
+		mac := strings.TrimSpace(string(data))
+		if mac != "" && mac != "00:00:00:00:00:00" {
+			iface.MacAddress = &mac
+		}
+	}
+
+	// Try to get IP addresses using the 'ip' command first (works in containers)
+	// This is more reliable when running in Docker/TrueNAS environments
+	if c.getIPAddressesViaIPCommand(ctx, iface) {
+		return
+	}
+
+	// Fallback to Go's net package
+	netIface, err := net.InterfaceByName(iface.Name)
+	if err != nil {
+		return
+	}
+
+	addrs, err := netIface.Addrs()
+	if err != nil {
+		return
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+
+		ip := ipNet.IP
+		// Skip loopback addresses
+		if ip.IsLoopback() {
+			continue
+		}
+
+		// Check if IPv4 or IPv6
+		if ipv4 := ip.To4(); ipv4 != nil {
+			// IPv4 address
+			if iface.Ipv4Address == nil {
+				ipv4Str := ipv4.String()
+				iface.Ipv4Address = &ipv4Str
+			}
+		} else if ipv6 := ip.To16(); ipv6 != nil {
+			// IPv6 address (skip link-local)
+			if !ip.IsLinkLocalUnicast() && iface.Ipv6Address == nil {
+				ipv6Str := ipv6.String()
+				iface.Ipv6Address = &ipv6Str
+			}
+		}
+	}
+}
+
+// getIPAddressesViaIPCommand uses the 'ip' command to get IP addresses
+// This works better in containerized environments (e.g., TrueNAS SCALE)
+func (c *Collector) getIPAddressesViaIPCommand(ctx context.Context, iface *NetworkInterface) bool {
+	// Validate interface name to prevent command injection
+	// Interface names should only contain alphanumeric, hyphens, underscores, and dots
+	if !isValidInterfaceName(iface.Name) {
+		return false
+	}
+
+	// Execute 'ip addr show <interface>'
+	// Killed when the collector's deadline passes
+	cmd := exec.CommandContext(ctx, "ip", "addr", "show", iface.Name)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return false
+	}
+
+	// Parse the output for inet and inet6 addresses
+	lines := strings.Split(string(output), "\n")
+	foundAny := false
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+
+		// Look for "inet" lines
+		if strings.HasPrefix(line, "inet ") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				// Extract IP address (remove /prefix if present)
+				ipWithMask := fields[1]
+				ip := ipWithMask
+				if idx := strings.Index(ipWithMask, "/"); idx != -1 {
+					ip = ipWithMask[:idx]
+				}
+
+				// Validate and store IPv4
+				if parsedIP := net.ParseIP(ip); parsedIP != nil && parsedIP.To4() != nil {
+					if !parsedIP.IsLoopback() && iface.Ipv4Address == nil {
+						iface.Ipv4Address = &ip
+						foundAny = true
+					}
+				}
+			}
+		} else if strings.HasPrefix(line, "inet6 ") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				// Extract IPv6 address (remove /prefix if present)
+				ipWithMask := fields[1]
+				ip := ipWithMask
+				if idx := strings.Index(ipWithMask, "/"); idx != -1 {
+					ip = ipWithMask[:idx]
+				}
+
+				// Validate and store IPv6 (skip link-local)
+				if parsedIP := net.ParseIP(ip); parsedIP != nil {
+					if !parsedIP.IsLoopback() && !parsedIP.IsLinkLocalUnicast() && iface.Ipv6Address == nil {
+						iface.Ipv6Address = &ip
+						foundAny = true
+					}
+				}
+			}
+		}
+	}
+
+	return foundAny
+}
+
+// isValidInterfaceName checks if an interface name is safe to use in commands
+// Prevents command injection by ensuring only valid characters are present
+func isValidInterfaceName(name string) bool {
+	if name == "" || len(name) > 15 { // Linux interface names are max 15 chars
+		return false
+	}
+
+	for _, ch := range name {
+		if !((ch >= 'a' && ch <= 'z') ||
+			(ch >= 'A' && ch <= 'Z') ||
+			(ch >= '0' && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// getExternalIPv4 attempts to fetch the external IPv4 address using a public IP service
+// Returns empty string on failure (best effort, non-blocking with timeout)
+func (c *Collector) getExternalIPv4(ctx context.Context) string {
+	// Use a short timeout to avoid blocking stats collection
+	client := &http.Client{
+		Timeout: 2 * time.Second,
+	}
+
+	// Try multiple services in case one is down
+	services := []string{
+		"https://api.ipify.org",
+		"https://icanhazip.com",
+		"https://ifconfig.me/ip",
+	}
+
+	for _, service := range services {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, service, nil)
+		if err != nil {
+			continue
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "" // Out of time, don't try the other services
+			}
+			continue
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			continue
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			continue
+		}
+
+		ip := strings.TrimSpace(string(body))
+		// Validate it's a proper IPv4 address
+		if parsedIP := net.ParseIP(ip); parsedIP != nil && parsedIP.To4() != nil {
+			return ip
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,152 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pressureResources are the PSI files read for the host and each cgroup.
+var pressureResources = []string{"cpu", "io", "memory"}
+
+// parsePressure parses a PSI file such as /proc/pressure/io:
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//
+// Kernels before 5.13 have no "full" line for cpu.
+func parsePressure(data string) (some, full *PressureLine) {
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || (fields[0] != "some" && fields[0] != "full") {
+			continue
+		}
+		pl := &PressureLine{}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "avg10":
+				pl.Avg10, _ = strconv.ParseFloat(value, 64)
+			case "avg60":
+				pl.Avg60, _ = strconv.ParseFloat(value, 64)
+			case "avg300":
+				pl.Avg300, _ = strconv.ParseFloat(value, 64)
+			case "total":
+				pl.TotalUs, _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+		if fields[0] == "some" {
+			some = pl
+		} else {
+			full = pl
+		}
+	}
+	return some, full
+}
+
+// readPressure reads the PSI file of resource in dir and turns the stall
+// totals into rates under key.
+func (c *Collector) readPressure(dir, file, key string, now time.Time, bootID string) (*PressureResource, error) {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	some, full := parsePressure(string(data))
+	if some == nil {
+		return nil, fmt.Errorf("no \"some\" line in %s", filepath.Join(dir, file))
+	}
+	for name, pl := range map[string]*PressureLine{"some": some, "full": full} {
+		if pl == nil {
+			continue
+		}
+		pl.StallUsPerSec, _ = c.pressureCounters.rate(key+"/"+name, pl.TotalUs, now, bootID)
+	}
+	return &PressureResource{Some: some, Full: full}, nil
+}
+
+// cgroupRoot returns the cgroup v2 hierarchy: /sys/fs/cgroup on unified
+// systems, /sys/fs/cgroup/unified in hybrid mode.
+func (c *Collector) cgroupRoot() string {
+	root := filepath.Join(c.sysPath, "fs/cgroup")
+	if fileExists(filepath.Join(root, "cgroup.controllers")) {
+		return root
+	}
+	if unified := filepath.Join(root, "unified"); fileExists(filepath.Join(unified, "cgroup.controllers")) {
+		return unified
+	}
+	return root
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// collectPressure reads host-wide PSI from /proc/pressure and the PSI of
+// each configured cgroup. Kernels built without PSI, or booted with psi=0,
+// have no pressure files; that leaves the section unavailable without an
+// error. A configured cgroup that can't be read is an error.
+func (c *Collector) collectPressure(ctx context.Context) *PressureStats {
+	stats := &PressureStats{Available: false}
+	now := time.Now()
+	bootID := c.bootID()
+
+	hostDir := filepath.Join(c.procPath, "pressure")
+	for _, resource := range pressureResources {
+		pr, err := c.readPressure(hostDir, resource, "host/"+resource, now, bootID)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				c.logError(ctx, "pressure", readErrorCode(err), fmt.Sprintf("failed to read %s: %v", filepath.Join(hostDir, resource), err))
+			}
+			continue
+		}
+		stats.Available = true
+		switch resource {
+		case "cpu":
+			stats.CPU = pr
+		case "io":
+			stats.IO = pr
+		case "memory":
+			stats.Memory = pr
+		}
+	}
+
+	root := c.cgroupRoot()
+	for _, path := range c.pressureCgroups {
+		cg := CgroupPressure{Path: "/" + path}
+		dir := filepath.Join(root, path)
+		if _, err := os.Stat(dir); err != nil {
+			c.logError(ctx, "pressure", readErrorCode(err), fmt.Sprintf("cgroup %s: %v", cg.Path, err))
+			continue
+		}
+		for _, resource := range pressureResources {
+			pr, err := c.readPressure(dir, resource+".pressure", "cgroup:"+path+"/"+resource, now, bootID)
+			if err != nil {
+				c.logError(ctx, "pressure", readErrorCode(err), fmt.Sprintf("failed to read PSI of cgroup %s: %v", cg.Path, err))
+				continue
+			}
+			switch resource {
+			case "cpu":
+				cg.CPU = pr
+			case "io":
+				cg.IO = pr
+			case "memory":
+				cg.Memory = pr
+			}
+		}
+		if cg.CPU != nil || cg.IO != nil || cg.Memory != nil {
+			stats.Cgroups = append(stats.Cgroups, cg)
+			stats.Available = true
+		}
+	}
+
+	c.pressureCounters.prune(now)
+	return stats
+}
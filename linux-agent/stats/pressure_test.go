@@ -0,0 +1,97 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePressure(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantSome *PressureLine
+		wantFull *PressureLine
+	}{
+		{
+			name: "some and full",
+			data: "some avg10=1.53 avg60=0.87 avg300=0.25 total=24715289\n" +
+				"full avg10=0.40 avg60=0.12 avg300=0.03 total=8172633\n",
+			wantSome: &PressureLine{Avg10: 1.53, Avg60: 0.87, Avg300: 0.25, TotalUs: 24715289},
+			wantFull: &PressureLine{Avg10: 0.40, Avg60: 0.12, Avg300: 0.03, TotalUs: 8172633},
+		},
+		{
+			name:     "cpu before 5.13 has no full line",
+			data:     "some avg10=12.00 avg60=9.50 avg300=4.10 total=991002345\n",
+			wantSome: &PressureLine{Avg10: 12, Avg60: 9.5, Avg300: 4.1, TotalUs: 991002345},
+		},
+		{
+			name: "cgroup cpu.pressure with an all-zero full line",
+			data: "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n" +
+				"full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+			wantSome: &PressureLine{},
+			wantFull: &PressureLine{},
+		},
+		{
+			name:     "unknown lines and fields are ignored",
+			data:     "some avg10=2.00 extra avg60=1.00 avg5=9.99 avg300=0.50 total=100\nnone avg10=5.00\n",
+			wantSome: &PressureLine{Avg10: 2, Avg60: 1, Avg300: 0.5, TotalUs: 100},
+		},
+		{
+			name: "empty",
+			data: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			some, full := parsePressure(tt.data)
+			if !reflect.DeepEqual(some, tt.wantSome) {
+				t.Errorf("some = %+v, want %+v", some, tt.wantSome)
+			}
+			if !reflect.DeepEqual(full, tt.wantFull) {
+				t.Errorf("full = %+v, want %+v", full, tt.wantFull)
+			}
+		})
+	}
+}
+
+func TestReadPressure(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantErr  string
+		wantFull bool
+	}{
+		{name: "some and full", data: "some avg10=0.00 avg60=0.00 avg300=0.00 total=10\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=5\n", wantFull: true},
+		{name: "no full line", data: "some avg10=0.00 avg60=0.00 avg300=0.00 total=10\n"},
+		{name: "no some line", data: "full avg10=0.00 avg60=0.00 avg300=0.00 total=5\n", wantErr: `no "some" line`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "cpu.pressure"), []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			c := &Collector{pressureCounters: newCounterTracker()}
+
+			res, err := c.readPressure(dir, "cpu.pressure", "host/cpu", time.Now(), "")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readPressure() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Some == nil || (res.Full != nil) != tt.wantFull {
+				t.Errorf("readPressure() = some %+v, full %+v; want full: %v", res.Some, res.Full, tt.wantFull)
+			}
+		})
+	}
+}
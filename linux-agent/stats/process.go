@@ -0,0 +1,396 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sort keys for Processes.
+const (
+	ProcessSortCPU    = "cpu"
+	ProcessSortMemory = "memory"
+	ProcessSortIO     = "io"
+	ProcessSortRead   = "read"
+	ProcessSortWrite  = "write"
+)
+
+var processSortKeys = []string{ProcessSortCPU, ProcessSortMemory, ProcessSortIO, ProcessSortRead, ProcessSortWrite}
+
+// MaxProcessLimit is the most processes Processes returns per sort key. The
+// collector keeps full details for the top MaxProcessLimit processes by
+// every sort key and only the cheap /proc/[pid]/stat and io figures for
+// the rest, so hosts with thousands of PIDs stay affordable.
+const MaxProcessLimit = 100
+
+const (
+	// userHZ is the unit of the /proc/[pid]/stat times. It is 100 on every
+	// Linux architecture the agent runs on.
+	userHZ = 100
+	// topProcesses is how many of the busiest processes go in /v1/stats.
+	topProcesses = 5
+	// maxCmdlineLen bounds a reported command line.
+	maxCmdlineLen = 1024
+)
+
+// containerIDPattern matches the 64 hex digit container IDs that Docker,
+// containerd and CRI-O put in cgroup paths.
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// ProcessList is the result of Processes.
+type ProcessList struct {
+	CollectedAt int64         `json:"collectedAt"`
+	Sort        string        `json:"sort"`
+	Total       int           `json:"total"`
+	Processes   []ProcessInfo `json:"processes"`
+}
+
+// procIdentity is what rarely changes over a process's life, cached by
+// PID and start time. The owner and cgroup are read for every process, the
+// command line only once a process is detailed.
+type procIdentity struct {
+	uid         *int
+	user        string
+	cgroup      string
+	containerID string
+	cmdline     string
+	haveCmdline bool
+}
+
+// processKey identifies a process across PID reuse.
+func processKey(pid int, startTime uint64) string {
+	return strconv.Itoa(pid) + "/" + strconv.FormatUint(startTime, 10)
+}
+
+// ticksDuration converts clock ticks to a duration. The tick length is
+// applied first: ticks * time.Second overflows for anything started more
+// than about three years after boot.
+func ticksDuration(ticks uint64) time.Duration {
+	return time.Duration(ticks) * (time.Second / userHZ)
+}
+
+// bootTime returns the boot time from the btime line of /proc/stat.
+func (c *Collector) bootTime() (time.Time, bool) {
+	data, err := os.ReadFile(filepath.Join(c.procPath, "stat"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "btime "); ok {
+			sec, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, false
+			}
+			return time.Unix(sec, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// readProcIO returns the bytes a process caused to be read from and written
+// to storage. Other users' io files need ptrace access, so this fails for
+// them unless the agent runs privileged.
+func readProcIO(path string) (read, write uint64, ok bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	found := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, _ := strings.Cut(line, ": ")
+		switch key {
+		case "read_bytes":
+			read, _ = strconv.ParseUint(value, 10, 64)
+			found++
+		case "write_bytes":
+			write, _ = strconv.ParseUint(value, 10, 64)
+			found++
+		}
+	}
+	return read, write, found == 2
+}
+
+// readCmdline reads the command line of a process.
+func readCmdline(dir, comm string) string {
+	var cmdline string
+	if data, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		cmdline = strings.TrimSpace(string(bytes.ReplaceAll(bytes.TrimRight(data, "\x00"), []byte{0}, []byte{' '})))
+		if len(cmdline) > maxCmdlineLen {
+			// Don't leave half a UTF-8 sequence at the cut
+			cmdline = strings.ToValidUTF8(cmdline[:maxCmdlineLen], "")
+		}
+	}
+	if cmdline == "" {
+		// Kernel threads have no command line; show them the way ps does
+		cmdline = "[" + comm + "]"
+	}
+	return cmdline
+}
+
+// readIdentity reads the owner and cgroup of a process.
+func (c *Collector) readIdentity(dir string) procIdentity {
+	var id procIdentity
+
+	if data, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if value, ok := strings.CutPrefix(line, "Uid:"); ok {
+				if fields := strings.Fields(value); len(fields) > 0 {
+					if uid, err := strconv.Atoi(fields[0]); err == nil {
+						id.uid = &uid
+						id.user = c.userName(uid)
+					}
+				}
+				break
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "cgroup")); err == nil {
+		id.cgroup = parseProcCgroup(string(data))
+		if match := containerIDPattern.FindString(id.cgroup); match != "" {
+			id.containerID = match[:12]
+		}
+	}
+	return id
+}
+
+// parseProcCgroup returns the cgroup v2 path from /proc/[pid]/cgroup, or
+// on cgroup v1 hosts the path of the systemd hierarchy or the first one.
+func parseProcCgroup(data string) string {
+	var first, systemd string
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			return parts[2]
+		}
+		if parts[1] == "name=systemd" {
+			systemd = parts[2]
+		}
+		if first == "" {
+			first = parts[2]
+		}
+	}
+	if systemd != "" {
+		return systemd
+	}
+	return first
+}
+
+// userName resolves uid, caching the result. Unknown uids (users that
+// only exist on the host when running in a container) stay numeric.
+func (c *Collector) userName(uid int) string {
+	if name, ok := c.userNames[uid]; ok {
+		return name
+	}
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	c.userNames[uid] = name
+	return name
+}
+
+// sortProcesses orders list by key, highest first. Processes without a
+// value for key sort last.
+func sortProcesses(list []ProcessInfo, key string) {
+	value := func(p ProcessInfo) float64 {
+		switch key {
+		case ProcessSortMemory:
+			return float64(p.RSSBytes)
+		case ProcessSortRead:
+			return valueOr(p.ReadBytesPerSec, -1)
+		case ProcessSortWrite:
+			return valueOr(p.WriteBytesPerSec, -1)
+		case ProcessSortIO:
+			if p.ReadBytesPerSec == nil || p.WriteBytesPerSec == nil {
+				return -1
+			}
+			return *p.ReadBytesPerSec + *p.WriteBytesPerSec
+		default:
+			return valueOr(p.CPUPercent, -1)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		vi, vj := value(list[i]), value(list[j])
+		if vi != vj {
+			return vi > vj
+		}
+		return list[i].PID < list[j].PID
+	})
+}
+
+func valueOr(v *float64, fallback float64) float64 {
+	if v == nil {
+		return fallback
+	}
+	return *v
+}
+
+// collectProcesses samples every process and rolls them up into groups.
+// CPU and I/O rates come from successive samples of the same process, so a
+// process shows them from its second sample on. The walk reads two files
+// per process, plus its owner and cgroup once in its life. The command line
+// and private memory are read only for processes that rank in the top
+// MaxProcessLimit by some sort key.
+func (c *Collector) collectProcesses(ctx context.Context) (*ProcessStats, *GroupStats) {
+	stats := &ProcessStats{Available: false}
+
+	pids, err := c.listPIDs()
+	if err != nil {
+		c.logError(ctx, "processes", readErrorCode(err), fmt.Sprintf("failed to list %s: %v", c.procPath, err))
+		return stats, &GroupStats{Available: false}
+	}
+	bootTime, haveBootTime := c.bootTime()
+	now := time.Now()
+	bootID := c.bootID()
+	pageSize := uint64(os.Getpagesize())
+
+	all := make([]ProcessInfo, 0, len(pids))
+	keys := make(map[int]string, len(pids))
+	identities := make(map[string]procIdentity, len(pids))
+	for _, pid := range pids {
+		// A run that timed out is discarded; leave the baselines and
+		// caches of the processes not visited yet alone
+		if ctx.Err() != nil {
+			return stats, &GroupStats{Available: false}
+		}
+		dir := filepath.Join(c.procPath, strconv.Itoa(pid))
+		data, err := os.ReadFile(filepath.Join(dir, "stat"))
+		if err != nil {
+			continue // Exited
+		}
+		ps, err := parseProcStat(string(data))
+		if err != nil {
+			continue
+		}
+		key := processKey(pid, ps.StartTime)
+		keys[pid] = key
+
+		p := ProcessInfo{
+			PID:      pid,
+			PPID:     ps.PPID,
+			Name:     ps.Comm,
+			State:    string(ps.State),
+			Threads:  ps.NumThreads,
+			RSSBytes: ps.RSSPages * pageSize,
+		}
+		id, ok := c.procIdentities[key]
+		if !ok {
+			id = c.readIdentity(dir)
+		}
+		identities[key] = id
+		p.UID, p.User, p.Cgroup, p.ContainerID = id.uid, id.user, id.cgroup, id.containerID
+		if haveBootTime {
+			p.StartTime = bootTime.Add(ticksDuration(ps.StartTime)).UnixMilli()
+		}
+		if ticks, _ := c.procCounters.rate(key+"/cpu", ps.UTime+ps.STime, now, bootID); ticks != nil {
+			pct := *ticks / userHZ * 100.0
+			p.CPUPercent = &pct
+		}
+		if read, write, ok := readProcIO(filepath.Join(dir, "io")); ok {
+			p.ReadBytesPerSec, _ = c.procCounters.rate(key+"/read", read, now, bootID)
+			p.WriteBytesPerSec, _ = c.procCounters.rate(key+"/write", write, now, bootID)
+		}
+		all = append(all, p)
+	}
+	c.procCounters.prune(now)
+
+	// Details for the union of the top processes by every sort key
+	candidates := make(map[int]bool)
+	for _, key := range processSortKeys {
+		sortProcesses(all, key)
+		for i := 0; i < len(all) && i < MaxProcessLimit; i++ {
+			candidates[all[i].PID] = true
+		}
+	}
+	detailed := make([]ProcessInfo, 0, len(candidates))
+	for _, p := range all {
+		if !candidates[p.PID] {
+			continue
+		}
+		dir := filepath.Join(c.procPath, strconv.Itoa(p.PID))
+		key := keys[p.PID]
+		id := identities[key]
+		if !id.haveCmdline {
+			id.cmdline, id.haveCmdline = readCmdline(dir, p.Name), true
+			identities[key] = id
+		}
+		p.Cmdline = id.cmdline
+
+		// statm: size resident shared text lib data dt, in pages
+		if data, err := os.ReadFile(filepath.Join(dir, "statm")); err == nil {
+			fields := strings.Fields(string(data))
+			if len(fields) >= 3 {
+				resident, _ := strconv.ParseUint(fields[1], 10, 64)
+				shared, _ := strconv.ParseUint(fields[2], 10, 64)
+				if resident >= shared {
+					private := (resident - shared) * pageSize
+					p.PrivateBytes = &private
+				}
+			}
+		}
+		detailed = append(detailed, p)
+	}
+	if ctx.Err() != nil {
+		return stats, &GroupStats{Available: false}
+	}
+	// Identities of processes that exited are dropped
+	c.procIdentities = identities
+
+	c.procMu.Lock()
+	c.procList = detailed
+	c.procTotal = len(all)
+	c.procCollectedAt = now
+	c.procMu.Unlock()
+
+	stats.Available = len(all) > 0
+	stats.Count = len(all)
+	sortProcesses(detailed, ProcessSortCPU)
+	for i := 0; i < len(detailed) && i < topProcesses; i++ {
+		stats.Top = append(stats.Top, detailed[i])
+	}
+	return stats, groupProcesses(all)
+}
+
+// Processes returns the top limit processes of the latest sample by
+// sortKey: cpu (the default), memory, io, read or write. limit is capped
+// at MaxProcessLimit.
+func (c *Collector) Processes(sortKey string, limit int) (ProcessList, error) {
+	if sortKey == "" {
+		sortKey = ProcessSortCPU
+	}
+	valid := false
+	for _, key := range processSortKeys {
+		valid = valid || key == sortKey
+	}
+	if !valid {
+		return ProcessList{}, fmt.Errorf("unknown sort key %q (valid: %s)", sortKey, strings.Join(processSortKeys, ", "))
+	}
+	if limit <= 0 || limit > MaxProcessLimit {
+		limit = MaxProcessLimit
+	}
+
+	c.procMu.Lock()
+	list := ProcessList{Sort: sortKey, Total: c.procTotal, Processes: append([]ProcessInfo(nil), c.procList...)}
+	if !c.procCollectedAt.IsZero() {
+		list.CollectedAt = c.procCollectedAt.UnixMilli()
+	}
+	c.procMu.Unlock()
+
+	sortProcesses(list.Processes, sortKey)
+	if len(list.Processes) > limit {
+		list.Processes = list.Processes[:limit]
+	}
+	return list, nil
+}
@@ -0,0 +1,502 @@
+package stats
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RedactionAction says what happens to a sensitive field.
+type RedactionAction string
+
+const (
+	RedactKeep RedactionAction = ""
+	RedactDrop RedactionAction = "drop"
+	RedactHash RedactionAction = "hash"
+)
+
+// Fields that can be redacted, keyed by their JSON name.
+const (
+	RedactFieldMacAddress   = "macAddress"
+	RedactFieldIpv4Address  = "ipv4Address"
+	RedactFieldIpv6Address  = "ipv6Address"
+	RedactFieldExternalIpv4 = "externalIpv4"
+	RedactFieldDevice       = "device"
+	RedactFieldHostname     = "hostname"
+	RedactFieldCmdline      = "cmdline"
+	RedactFieldUser         = "user"
+	RedactFieldProcess      = "process"
+)
+
+var redactableFields = map[string]bool{
+	RedactFieldMacAddress:   true,
+	RedactFieldIpv4Address:  true,
+	RedactFieldIpv6Address:  true,
+	RedactFieldExternalIpv4: true,
+	RedactFieldDevice:       true,
+	RedactFieldHostname:     true,
+	RedactFieldCmdline:      true,
+	RedactFieldUser:         true,
+	RedactFieldProcess:      true,
+}
+
+// GuestRedaction is the built-in "guest" policy: addresses and command
+// lines (which can carry credentials) are removed and identifiers are
+// replaced by stable hashes so the UI can still tell interfaces, disks,
+// users and processes apart.
+var GuestRedaction = map[string]RedactionAction{
+	RedactFieldMacAddress:   RedactHash,
+	RedactFieldIpv4Address:  RedactDrop,
+	RedactFieldIpv6Address:  RedactDrop,
+	RedactFieldExternalIpv4: RedactDrop,
+	RedactFieldDevice:       RedactHash,
+	RedactFieldHostname:     RedactHash,
+	RedactFieldCmdline:      RedactDrop,
+	RedactFieldUser:         RedactHash,
+	RedactFieldProcess:      RedactHash,
+}
+
+// maxRememberedValues bounds the redacted values a policy keeps for
+// rewriting error messages.
+const maxRememberedValues = 4096
+
+// Patterns for values that can turn up in error messages without being in
+// the snapshot any more. IPv6 candidates are checked with net.ParseIP, so
+// times such as 10:00:00 are left alone. Device paths are /dev nodes, NFS
+// exports and SMB shares; the last two must start a word so URLs don't
+// match.
+var (
+	macPattern    = regexp.MustCompile(`(?i)\b[0-9a-f]{2}(?::[0-9a-f]{2}){5}\b`)
+	ipv6Pattern   = regexp.MustCompile(`[0-9A-Fa-f.]*:[0-9A-Fa-f.]*:[0-9A-Fa-f:.]*`)
+	ipv4Pattern   = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+	devicePattern = regexp.MustCompile(`/dev/[^\s:,;'"()]+|(?:^|\s)(//[^\s/]+/[^\s:,;'"()]*|[\w.-]+:/[^\s:,;'"()/][^\s:,;'"()]*)`)
+)
+
+// RedactionPolicy removes or hashes identifying fields from a snapshot.
+// It remembers the values it has redacted so error messages about a mount
+// or interface that has since gone are still rewritten.
+type RedactionPolicy struct {
+	actions map[string]RedactionAction
+	hashKey []byte
+
+	mu         sync.Mutex
+	remembered map[string]string // Original -> redacted value
+}
+
+// NewRedactionPolicy validates a field -> action map. Hashes are keyed
+// HMACs so values can't be recovered by hashing candidate addresses.
+func NewRedactionPolicy(actions map[string]RedactionAction, hashKey []byte) (*RedactionPolicy, error) {
+	p := &RedactionPolicy{
+		actions:    make(map[string]RedactionAction),
+		hashKey:    hashKey,
+		remembered: make(map[string]string),
+	}
+	for field, action := range actions {
+		if !redactableFields[field] {
+			return nil, fmt.Errorf("unknown redaction field %q (valid: %s)", field, strings.Join(RedactableFields(), ", "))
+		}
+		switch action {
+		case RedactKeep, RedactDrop, RedactHash:
+		default:
+			return nil, fmt.Errorf("unknown redaction action %q for %s (valid: drop, hash)", action, field)
+		}
+		if action != RedactKeep {
+			p.actions[field] = action
+		}
+	}
+	return p, nil
+}
+
+// RedactableFields returns the names of the fields a policy can cover.
+func RedactableFields() []string {
+	fields := make([]string, 0, len(redactableFields))
+	for field := range redactableFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func (p *RedactionPolicy) hash(value string) string {
+	mac := hmac.New(sha256.New, p.hashKey)
+	mac.Write([]byte(value))
+	return "h:" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// redactString applies the field's action to value. The bool result is
+// false when the value should be dropped.
+func (p *RedactionPolicy) redactString(field, value string) (string, bool) {
+	switch p.actions[field] {
+	case RedactDrop:
+		return "", false
+	case RedactHash:
+		return p.hash(value), true
+	default:
+		return value, true
+	}
+}
+
+func (p *RedactionPolicy) redactPtr(field string, value *string, replaced map[string]string) *string {
+	if value == nil || p.actions[field] == RedactKeep {
+		return value
+	}
+	redacted, ok := p.redactString(field, *value)
+	replaced[*value] = redacted
+	if !ok {
+		return nil
+	}
+	return &redacted
+}
+
+// Apply returns a redacted copy of s. The input is never modified, so a
+// cached snapshot can be shared between callers with different policies.
+// Error messages mentioning a redacted value are rewritten too.
+func (p *RedactionPolicy) Apply(s *RemoteLinuxStats) *RemoteLinuxStats {
+	if p == nil || len(p.actions) == 0 || s == nil {
+		return s
+	}
+	out, _ := p.apply(s)
+	return out
+}
+
+// ApplyErrors returns a copy of report with every redacted value rewritten
+// in the error messages: the values redacted from earlier snapshots, and
+// anything that looks like an address or a device path.
+func (p *RedactionPolicy) ApplyErrors(report ErrorReport) ErrorReport {
+	if p == nil || len(p.actions) == 0 {
+		return report
+	}
+	return ErrorReport{
+		Active:    p.redactErrors(report.Active, nil),
+		Recovered: p.redactErrors(report.Recovered, nil),
+	}
+}
+
+// Remembers reports whether the policy has redacted any values yet, and so
+// knows what to look for in messages.
+func (p *RedactionPolicy) Remembers() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.remembered) > 0
+}
+
+// ApplyCapabilities returns a copy of report with redacted values rewritten
+// in the reasons.
+func (p *RedactionPolicy) ApplyCapabilities(report CapabilityReport) CapabilityReport {
+	if p == nil || len(p.actions) == 0 {
+		return report
+	}
+	out := CapabilityReport{ProbedAt: report.ProbedAt}
+	if report.Collectors != nil {
+		out.Collectors = make([]CollectorCapability, len(report.Collectors))
+		for i, c := range report.Collectors {
+			c.Reason = p.redactText(c.Reason, nil)
+			out.Collectors[i] = c
+		}
+	}
+	if report.Features != nil {
+		out.Features = make(map[string]Capability, len(report.Features))
+		for name, c := range report.Features {
+			c.Reason = p.redactText(c.Reason, nil)
+			out.Features[name] = c
+		}
+	}
+	return out
+}
+
+// RedactText rewrites redacted values in free text, such as a log line or
+// an address outside the snapshot.
+func (p *RedactionPolicy) RedactText(text string) string {
+	if p == nil || len(p.actions) == 0 {
+		return text
+	}
+	return p.redactText(text, nil)
+}
+
+// apply redacts s and returns the copy along with the original -> redacted
+// values, for rewriting free text.
+func (p *RedactionPolicy) apply(s *RemoteLinuxStats) (*RemoteLinuxStats, map[string]string) {
+	out := *s
+	replaced := make(map[string]string)
+
+	if p.actions[RedactFieldHostname] != RedactKeep && s.Hostname != "" {
+		out.Hostname, _ = p.redactString(RedactFieldHostname, s.Hostname)
+		replaced[s.Hostname] = out.Hostname
+	}
+
+	if s.Network != nil {
+		network := *s.Network
+		network.ExternalIPv4 = p.redactPtr(RedactFieldExternalIpv4, s.Network.ExternalIPv4, replaced)
+		if s.Network.Interfaces != nil {
+			network.Interfaces = make([]NetworkInterface, len(s.Network.Interfaces))
+			for i, iface := range s.Network.Interfaces {
+				iface.MacAddress = p.redactPtr(RedactFieldMacAddress, iface.MacAddress, replaced)
+				iface.Ipv4Address = p.redactPtr(RedactFieldIpv4Address, iface.Ipv4Address, replaced)
+				iface.Ipv6Address = p.redactPtr(RedactFieldIpv6Address, iface.Ipv6Address, replaced)
+				network.Interfaces[i] = iface
+			}
+		}
+		out.Network = &network
+	}
+
+	if s.Disk != nil && s.Disk.Filesystems != nil && p.actions[RedactFieldDevice] != RedactKeep {
+		disk := *s.Disk
+		disk.Filesystems = make([]Filesystem, len(s.Disk.Filesystems))
+		for i, fs := range s.Disk.Filesystems {
+			redacted, _ := p.redactString(RedactFieldDevice, fs.Device)
+			replaced[fs.Device] = redacted
+			fs.Device = redacted
+			disk.Filesystems[i] = fs
+		}
+		out.Disk = &disk
+	}
+
+	if s.Processes != nil && s.Processes.Top != nil {
+		processes := *s.Processes
+		processes.Top = p.redactProcesses(s.Processes.Top)
+		out.Processes = &processes
+	}
+
+	if s.Groups != nil {
+		out.Groups = p.redactGroups(s.Groups)
+	}
+
+	if s.Errors != nil {
+		out.Errors = make([]string, len(s.Errors))
+		for i, msg := range s.Errors {
+			out.Errors[i] = p.redactText(msg, replaced)
+		}
+	}
+	p.redactSectionErrors(&out, replaced)
+
+	p.remember(replaced)
+	return &out, replaced
+}
+
+// redactGroups copies groups with the keys of the redacted rollups hashed.
+// Dropping a field drops its rollups: owners for user; names, units,
+// slices and trees for process.
+func (p *RedactionPolicy) redactGroups(groups *GroupStats) *GroupStats {
+	out := *groups
+	out.Users = p.redactGroupKeys(RedactFieldUser, groups.Users)
+	out.Names = p.redactGroupKeys(RedactFieldProcess, groups.Names)
+	out.Units = p.redactGroupKeys(RedactFieldProcess, groups.Units)
+	out.Slices = p.redactGroupKeys(RedactFieldProcess, groups.Slices)
+	out.Trees = p.redactGroupKeys(RedactFieldProcess, groups.Trees)
+	return &out
+}
+
+func (p *RedactionPolicy) redactGroupKeys(field string, groups []ProcessGroup) []ProcessGroup {
+	if groups == nil || p.actions[field] == RedactKeep {
+		return groups
+	}
+	var out []ProcessGroup
+	for _, g := range groups {
+		if key, ok := p.redactString(field, g.Key); ok {
+			g.Key = key
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// ApplyProcesses returns a copy of list with command lines, users and
+// process names redacted.
+func (p *RedactionPolicy) ApplyProcesses(list ProcessList) ProcessList {
+	if p == nil || len(p.actions) == 0 {
+		return list
+	}
+	list.Processes = p.redactProcesses(list.Processes)
+	return list
+}
+
+// redactProcesses copies processes with command lines, users and names
+// redacted; process covers the cgroup and container ID too. None of them
+// are added to the replaced values: short names such as "sh" or "root"
+// would mangle unrelated error messages.
+func (p *RedactionPolicy) redactProcesses(processes []ProcessInfo) []ProcessInfo {
+	if p.actions[RedactFieldCmdline] == RedactKeep && p.actions[RedactFieldUser] == RedactKeep &&
+		p.actions[RedactFieldProcess] == RedactKeep {
+		return processes
+	}
+	out := make([]ProcessInfo, len(processes))
+	for i, proc := range processes {
+		if proc.Cmdline != "" {
+			proc.Cmdline, _ = p.redactString(RedactFieldCmdline, proc.Cmdline)
+		}
+		if p.actions[RedactFieldUser] != RedactKeep {
+			if proc.User != "" {
+				proc.User, _ = p.redactString(RedactFieldUser, proc.User)
+			}
+			proc.UID = nil
+		}
+		if p.actions[RedactFieldProcess] != RedactKeep {
+			proc.Name, _ = p.redactString(RedactFieldProcess, proc.Name)
+			if proc.Cgroup != "" {
+				proc.Cgroup, _ = p.redactString(RedactFieldProcess, proc.Cgroup)
+			}
+			if proc.ContainerID != "" {
+				proc.ContainerID, _ = p.redactString(RedactFieldProcess, proc.ContainerID)
+			}
+		}
+		out[i] = proc
+	}
+	return out
+}
+
+// redactSectionErrors rewrites the structured errors of every section of
+// s, copying sections that are still shared with the input.
+func (p *RedactionPolicy) redactSectionErrors(s *RemoteLinuxStats, replaced map[string]string) {
+	v := reflect.ValueOf(s).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() != reflect.Ptr || field.IsNil() || field.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		errsField := field.Elem().FieldByName("Errors")
+		if !errsField.IsValid() {
+			continue
+		}
+		errs, ok := errsField.Interface().([]AgentError)
+		if !ok || len(errs) == 0 {
+			continue
+		}
+		section := reflect.New(field.Elem().Type())
+		section.Elem().Set(field.Elem())
+		section.Elem().FieldByName("Errors").Set(reflect.ValueOf(p.redactErrors(errs, replaced)))
+		field.Set(section)
+	}
+}
+
+func (p *RedactionPolicy) redactErrors(errs []AgentError, replaced map[string]string) []AgentError {
+	if errs == nil {
+		return nil
+	}
+	out := make([]AgentError, len(errs))
+	for i, e := range errs {
+		e.Message = p.redactText(e.Message, replaced)
+		out[i] = e
+	}
+	return out
+}
+
+// remember keeps the values redacted from a snapshot for later messages.
+// Past the limit an arbitrary older value is forgotten.
+func (p *RedactionPolicy) remember(replaced map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for value, redacted := range replaced {
+		if _, ok := p.remembered[value]; !ok && len(p.remembered) >= maxRememberedValues {
+			for old := range p.remembered {
+				delete(p.remembered, old)
+				break
+			}
+		}
+		p.remembered[value] = redacted
+	}
+}
+
+// redactText replaces every known redacted value that appears in msg,
+// longest first so an address isn't partially replaced by a shorter one,
+// then anything else that looks like an address or device path.
+func (p *RedactionPolicy) redactText(msg string, replaced map[string]string) string {
+	if msg == "" {
+		return msg
+	}
+	p.mu.Lock()
+	known := make(map[string]string, len(p.remembered)+len(replaced))
+	for value, redacted := range p.remembered {
+		known[value] = redacted
+	}
+	p.mu.Unlock()
+	for value, redacted := range replaced {
+		known[value] = redacted
+	}
+
+	values := make([]string, 0, len(known))
+	for value := range known {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		msg = strings.ReplaceAll(msg, value, redactedText(known[value]))
+	}
+
+	msg = p.redactPattern(msg, macPattern, func(string) string { return RedactFieldMacAddress })
+	msg = p.redactPattern(msg, ipv6Pattern, func(candidate string) string {
+		if ip := net.ParseIP(candidate); ip != nil {
+			if ip.To4() != nil {
+				return p.ipv4Field()
+			}
+			return RedactFieldIpv6Address
+		}
+		return ""
+	})
+	msg = p.redactPattern(msg, ipv4Pattern, func(candidate string) string {
+		if net.ParseIP(candidate) != nil {
+			return p.ipv4Field()
+		}
+		return ""
+	})
+	msg = p.redactPattern(msg, devicePattern, func(string) string { return RedactFieldDevice })
+	return msg
+}
+
+// ipv4Field is the field whose action applies to an IPv4 address found in
+// text, which may be an interface or the external address.
+func (p *RedactionPolicy) ipv4Field() string {
+	if p.actions[RedactFieldIpv4Address] == RedactKeep {
+		return RedactFieldExternalIpv4
+	}
+	return RedactFieldIpv4Address
+}
+
+// redactPattern redacts the matches of re in msg (the first group when re
+// has one) as the field fieldOf returns; an empty field or one the policy
+// keeps leaves the match alone. Trailing punctuation is not part of a
+// value.
+func (p *RedactionPolicy) redactPattern(msg string, re *regexp.Regexp, fieldOf func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(msg, -1) {
+		start, end := m[0], m[1]
+		if len(m) > 2 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		value := strings.TrimRight(msg[start:end], ".:")
+		if value == "" || strings.HasPrefix(value, "h:") {
+			continue
+		}
+		field := fieldOf(value)
+		if field == "" || p.actions[field] == RedactKeep {
+			continue
+		}
+		redacted, _ := p.redactString(field, value)
+		b.WriteString(msg[last:start])
+		b.WriteString(redactedText(redacted))
+		last = start + len(value)
+	}
+	if last == 0 {
+		return msg
+	}
+	b.WriteString(msg[last:])
+	return b.String()
+}
+
+// redactedText is what replaces a value in text: its hash, or a marker
+// when the value is dropped.
+func redactedText(redacted string) string {
+	if redacted == "" {
+		return "[redacted]"
+	}
+	return redacted
+}
@@ -0,0 +1,255 @@
+package stats
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestNewRedactionPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions map[string]RedactionAction
+		wantErr string
+	}{
+		{name: "guest", actions: GuestRedaction},
+		{name: "keep", actions: map[string]RedactionAction{RedactFieldHostname: RedactKeep}},
+		{name: "unknown field", actions: map[string]RedactionAction{"serial": RedactDrop}, wantErr: "unknown redaction field"},
+		{name: "unknown action", actions: map[string]RedactionAction{RedactFieldHostname: "blur"}, wantErr: "unknown redaction action"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRedactionPolicy(tt.actions, []byte("key"))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("NewRedactionPolicy() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("NewRedactionPolicy() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func redactionSnapshot() *RemoteLinuxStats {
+	uid := 1000
+	return &RemoteLinuxStats{
+		Hostname: "nas",
+		Network: &NetworkStats{
+			ExternalIPv4: strPtr("203.0.113.7"),
+			Interfaces: []NetworkInterface{{
+				Name:        "eth0",
+				MacAddress:  strPtr("aa:bb:cc:dd:ee:ff"),
+				Ipv4Address: strPtr("192.168.1.20"),
+				Ipv6Address: strPtr("fe80::1"),
+			}},
+		},
+		Disk: &DiskStats{Filesystems: []Filesystem{{Device: "/dev/sda1", MountPoint: "/"}}},
+		Processes: &ProcessStats{Top: []ProcessInfo{{
+			PID: 42, Name: "smbd", Cmdline: "smbd --password=x", UID: &uid, User: "alice",
+			Cgroup: "/system.slice/smbd.service",
+		}}},
+		Groups: &GroupStats{
+			Available: true,
+			Users:     []ProcessGroup{{Key: "alice"}},
+			Names:     []ProcessGroup{{Key: "smbd"}},
+			Units:     []ProcessGroup{{Key: "smbd.service"}},
+			Slices:    []ProcessGroup{{Key: "system.slice"}},
+			Trees:     []ProcessGroup{{Key: "smbd (42)"}},
+		},
+		Errors: []string{"nas: eth0 192.168.1.20 is down"},
+	}
+}
+
+func TestRedactionPolicyApply(t *testing.T) {
+	hashOnly := map[string]RedactionAction{}
+	for field := range redactableFields {
+		hashOnly[field] = RedactHash
+	}
+	dropAll := map[string]RedactionAction{}
+	for field := range redactableFields {
+		dropAll[field] = RedactDrop
+	}
+
+	tests := []struct {
+		name    string
+		actions map[string]RedactionAction
+		check   func(t *testing.T, p *RedactionPolicy, s *RemoteLinuxStats)
+	}{
+		{
+			name:    "no actions returns the input",
+			actions: nil,
+			check: func(t *testing.T, p *RedactionPolicy, s *RemoteLinuxStats) {
+				if !reflect.DeepEqual(s, redactionSnapshot()) {
+					t.Error("snapshot changed")
+				}
+			},
+		},
+		{
+			name:    "hash",
+			actions: hashOnly,
+			check: func(t *testing.T, p *RedactionPolicy, s *RemoteLinuxStats) {
+				iface := s.Network.Interfaces[0]
+				proc := s.Processes.Top[0]
+				for name, value := range map[string]string{
+					"hostname":      s.Hostname,
+					"externalIpv4":  *s.Network.ExternalIPv4,
+					"macAddress":    *iface.MacAddress,
+					"ipv4Address":   *iface.Ipv4Address,
+					"ipv6Address":   *iface.Ipv6Address,
+					"device":        s.Disk.Filesystems[0].Device,
+					"cmdline":       proc.Cmdline,
+					"user":          proc.User,
+					"process name":  proc.Name,
+					"cgroup":        proc.Cgroup,
+					"groups.users":  s.Groups.Users[0].Key,
+					"groups.names":  s.Groups.Names[0].Key,
+					"groups.units":  s.Groups.Units[0].Key,
+					"groups.slices": s.Groups.Slices[0].Key,
+					"groups.trees":  s.Groups.Trees[0].Key,
+				} {
+					if !strings.HasPrefix(value, "h:") {
+						t.Errorf("%s = %q, want a hash", name, value)
+					}
+				}
+				if proc.UID != nil {
+					t.Error("uid kept with user redacted")
+				}
+				if iface.Name != "eth0" || s.Disk.Filesystems[0].MountPoint != "/" {
+					t.Error("unredacted fields changed")
+				}
+				if s.Hostname != p.hash("nas") {
+					t.Error("hash is not stable")
+				}
+				if strings.Contains(s.Errors[0], "192.168.1.20") || strings.Contains(s.Errors[0], "nas") {
+					t.Errorf("error message not redacted: %q", s.Errors[0])
+				}
+			},
+		},
+		{
+			name:    "drop",
+			actions: dropAll,
+			check: func(t *testing.T, p *RedactionPolicy, s *RemoteLinuxStats) {
+				iface := s.Network.Interfaces[0]
+				if s.Network.ExternalIPv4 != nil || iface.MacAddress != nil || iface.Ipv4Address != nil || iface.Ipv6Address != nil {
+					t.Error("addresses kept")
+				}
+				if s.Hostname != "" || s.Disk.Filesystems[0].Device != "" {
+					t.Error("hostname or device kept")
+				}
+				if proc := s.Processes.Top[0]; proc.Cmdline != "" || proc.User != "" || proc.Name != "" || proc.Cgroup != "" {
+					t.Errorf("process fields kept: %+v", proc)
+				}
+				g := s.Groups
+				if g.Users != nil || g.Names != nil || g.Units != nil || g.Slices != nil || g.Trees != nil {
+					t.Error("group rollups kept")
+				}
+				if !g.Available {
+					t.Error("groups section marked unavailable")
+				}
+				if want := "[redacted]: eth0 [redacted] is down"; s.Errors[0] != want {
+					t.Errorf("error = %q, want %q", s.Errors[0], want)
+				}
+			},
+		},
+		{
+			name:    "guest",
+			actions: GuestRedaction,
+			check: func(t *testing.T, p *RedactionPolicy, s *RemoteLinuxStats) {
+				if s.Network.Interfaces[0].Ipv4Address != nil || s.Processes.Top[0].Cmdline != "" {
+					t.Error("guest kept an address or command line")
+				}
+				if !strings.HasPrefix(*s.Network.Interfaces[0].MacAddress, "h:") || !strings.HasPrefix(s.Groups.Names[0].Key, "h:") {
+					t.Error("guest didn't hash identifiers")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewRedactionPolicy(tt.actions, []byte("key"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			in := redactionSnapshot()
+			out := p.Apply(in)
+			if !reflect.DeepEqual(in, redactionSnapshot()) {
+				t.Error("Apply modified its input")
+			}
+			tt.check(t, p, out)
+		})
+	}
+}
+
+func TestRedactionPolicyText(t *testing.T) {
+	p, err := NewRedactionPolicy(GuestRedaction, []byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Remember the values of a snapshot that later goes away
+	p.Apply(&RemoteLinuxStats{Disk: &DiskStats{Filesystems: []Filesystem{{Device: "tank/media"}}}})
+
+	tests := []struct {
+		name    string
+		msg     string
+		want    string // Exact result, if set
+		removed []string
+	}{
+		{name: "plain", msg: "failed to read /proc/stat: permission denied", want: "failed to read /proc/stat: permission denied"},
+		{name: "time is not an address", msg: "stalled since 10:00:00", want: "stalled since 10:00:00"},
+		{name: "remembered device", msg: "tank/media is degraded", want: p.hash("tank/media") + " is degraded"},
+		{name: "ipv4", msg: "no route to 10.1.2.3.", want: "no route to [redacted]."},
+		{name: "ipv6", msg: "addr fe80::1 and ::ffff:10.0.0.1 gone", want: "addr [redacted] and [redacted] gone"},
+		{name: "mac", msg: "link aa:bb:cc:dd:ee:ff down", want: "link " + p.hash("aa:bb:cc:dd:ee:ff") + " down"},
+		{name: "dev path", msg: "statfs on /dev/sdb1 timed out", want: "statfs on " + p.hash("/dev/sdb1") + " timed out"},
+		{name: "nfs export", msg: "statfs nfs:/export timed out", removed: []string{"nfs:/export"}},
+		{name: "smb share", msg: "mount //server/share failed", removed: []string{"//server/share"}},
+		{name: "url is not a share", msg: "fetch https://example.com/ip failed", want: "fetch https://example.com/ip failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.RedactText(tt.msg)
+			if tt.want != "" && got != tt.want {
+				t.Errorf("RedactText(%q) = %q, want %q", tt.msg, got, tt.want)
+			}
+			for _, value := range tt.removed {
+				if strings.Contains(got, value) {
+					t.Errorf("RedactText(%q) = %q, still contains %q", tt.msg, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactionPolicyApplyErrors(t *testing.T) {
+	p, err := NewRedactionPolicy(GuestRedaction, []byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := ErrorReport{
+		Active:    []AgentError{{Component: "network", Message: "nas: 192.168.1.20 unreachable"}},
+		Recovered: []AgentError{{Component: "filesystems", Message: "statfs /dev/sdz1 timed out"}},
+	}
+	if p.Remembers() {
+		t.Error("new policy remembers values")
+	}
+	p.Apply(&RemoteLinuxStats{Hostname: "nas"})
+	if !p.Remembers() {
+		t.Error("policy didn't remember the hostname")
+	}
+	got := p.ApplyErrors(report)
+
+	if want := p.hash("nas") + ": [redacted] unreachable"; got.Active[0].Message != want {
+		t.Errorf("active message = %q, want %q", got.Active[0].Message, want)
+	}
+	if strings.Contains(got.Recovered[0].Message, "/dev/sdz1") {
+		t.Errorf("recovered message = %q, device not in the snapshot was kept", got.Recovered[0].Message)
+	}
+	if report.Active[0].Message != "nas: 192.168.1.20 unreachable" {
+		t.Error("ApplyErrors modified its input")
+	}
+}
@@ -0,0 +1,125 @@
+package stats
+
+import (
+	"context"
+	"time"
+)
+
+// sourceResult is the latest outcome of running one source.
+type sourceResult struct {
+	part        *RemoteLinuxStats // Last successful result, kept across failures
+	collectedAt time.Time
+	sections    []string // Sections part fills in
+	available   bool     // Whether any of them has data
+}
+
+// Start runs the sampler until ctx is cancelled. Each enabled source is
+// collected on its own cadence, so cheap sources like cpu refresh every
+// interval while filesystems or the external IP refresh every few minutes.
+// Collect serves the latest result of each.
+//
+// When no consumer has called Touch for IdleAfter, every source drops to
+// IdleInterval so the latest results stay reasonably fresh at a fraction
+// of the cost. The first Touch afterwards wakes the sampler immediately.
+func (c *Collector) Start(ctx context.Context) {
+	c.samplerStarted.Store(time.Now().UnixNano())
+	go c.schedule(ctx)
+}
+
+func (c *Collector) schedule(ctx context.Context) {
+	next := make(map[string]time.Time)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		woken := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-c.wake:
+			woken = true
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+
+		now := time.Now()
+		c.samplerTick.Store(now.UnixNano())
+		idle := c.updateIdle(now)
+		// Sleep until the next source is due. While idle that is up to
+		// IdleInterval away; Touch ends the sleep early through c.wake.
+		wake := now.Add(c.interval)
+		if idle {
+			wake = now.Add(c.idleInterval)
+		}
+		for _, source := range c.registry.Enabled() {
+			name := source.Name()
+			interval := c.registry.IntervalOf(source)
+			slowed := interval < c.idleInterval
+			if idle && slowed {
+				interval = c.idleInterval
+			}
+
+			due, ok := next[name]
+			switch {
+			case woken && ok && slowed:
+				// Counters were last read up to IdleInterval ago, so a rate
+				// computed now would be an average over the idle period.
+				// Take a fresh baseline instead and publish one interval
+				// later, when the rate covers a normal window again.
+				due = now.Add(interval)
+				next[name] = due
+				go c.baseline(source)
+			case !ok || !now.Before(due):
+				due = now.Add(interval)
+				next[name] = due
+				go c.sample(source)
+			}
+			if due.Before(wake) {
+				wake = due
+			}
+		}
+
+		timer.Reset(time.Until(wake))
+	}
+}
+
+// baseline runs a source only to refresh the previous counter values its
+// rates are computed from. The result is discarded; the idle-era section
+// keeps being served, with collectedAt showing its age, until the next
+// sample.
+func (c *Collector) baseline(source Source) {
+	c.runSource(source)
+}
+
+// sample runs one source and stores its result. A failed run keeps the
+// previous section so the snapshot stays complete, with the failure listed
+// in errors and the section's collectedAt showing its age.
+func (c *Collector) sample(source Source) {
+	start := time.Now()
+	part, errs := c.runSource(source)
+	duration := time.Since(start)
+	c.log.Debug("sampled source", "source", source.Name(), "duration", duration.String(), "errors", len(errs))
+	skipped := len(errs) == 1 && errs[0].Code == ErrCodeStillRunning
+	if !skipped {
+		c.self.recordRun(source.Name(), duration, part == nil, len(errs))
+	}
+	c.errors.update(source.Name(), errs, time.Now(), skipped)
+	if part == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.results[source.Name()] = &sourceResult{
+		part:        part,
+		collectedAt: time.Now(),
+		sections:    sectionsOf(part),
+		available:   anyAvailable(part),
+	}
+}
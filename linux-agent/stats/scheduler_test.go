@@ -0,0 +1,156 @@
+package stats
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingCollector returns a collector whose only source counts its runs
+// and publishes the run number as tasks.processes.
+func countingCollector(t *testing.T, opts Options) (*Collector, *atomic.Int64) {
+	t.Helper()
+	c := NewCollector(opts)
+	for _, source := range c.registry.Sources() {
+		if err := c.registry.SetEnabled(source.Name(), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs := &atomic.Int64{}
+	source := NewSource("counter", opts.Interval, func(ctx context.Context, s *RemoteLinuxStats) {
+		s.Tasks = &TaskStats{Available: true, Processes: int(runs.Add(1))}
+	})
+	if err := c.registry.Register(source); err != nil {
+		t.Fatal(err)
+	}
+	return c, runs
+}
+
+// counterResult returns the published run number and when it was
+// collected, or zero values if nothing is published yet.
+func counterResult(c *Collector) (int, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result, ok := c.results["counter"]
+	if !ok {
+		return 0, time.Time{}
+	}
+	return result.part.Tasks.Processes, result.collectedAt
+}
+
+// waitFor polls cond until it holds or timeout passes.
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerIdle(t *testing.T) {
+	tests := []struct {
+		name       string
+		idleAfter  time.Duration
+		touchEvery time.Duration // 0 for no client
+		wantIdle   bool
+		minRuns    int64 // Runs in the measured window
+		maxRuns    int64
+	}{
+		{name: "idle mode off", idleAfter: 0, wantIdle: false, minRuns: 5, maxRuns: 30},
+		{name: "no client slows down", idleAfter: 30 * time.Millisecond, wantIdle: true, minRuns: 0, maxRuns: 1},
+		{name: "polling client keeps full rate", idleAfter: 30 * time.Millisecond, touchEvery: 5 * time.Millisecond, wantIdle: false, minRuns: 5, maxRuns: 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, runs := countingCollector(t, Options{
+				Interval:     10 * time.Millisecond,
+				IdleAfter:    tt.idleAfter,
+				IdleInterval: time.Minute,
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.touchEvery > 0 {
+				go func() {
+					ticker := time.NewTicker(tt.touchEvery)
+					defer ticker.Stop()
+					for {
+						select {
+						case <-ctx.Done():
+							return
+						case <-ticker.C:
+							c.Touch("test")
+						}
+					}
+				}()
+			}
+			c.Start(ctx)
+
+			// Let the sampler settle, then count runs over a window well
+			// past IdleAfter
+			time.Sleep(100 * time.Millisecond)
+			before := runs.Load()
+			time.Sleep(200 * time.Millisecond)
+			got := runs.Load() - before
+
+			if c.Idle() != tt.wantIdle {
+				t.Errorf("Idle() = %v, want %v", c.Idle(), tt.wantIdle)
+			}
+			if got < tt.minRuns || got > tt.maxRuns {
+				t.Errorf("%d runs in 200ms, want %d to %d", got, tt.minRuns, tt.maxRuns)
+			}
+		})
+	}
+}
+
+func TestSchedulerWakeTakesBaseline(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	c, runs := countingCollector(t, Options{
+		Interval:     interval,
+		IdleAfter:    150 * time.Millisecond,
+		IdleInterval: time.Minute,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Start(ctx)
+
+	waitFor(t, 2*time.Second, "idle mode", c.Idle)
+	// The run started on the tick that went idle may still be in flight;
+	// give it time to start, then wait for it to be published
+	time.Sleep(interval / 2)
+	waitFor(t, time.Second, "the last run before idling", func() bool {
+		run, _ := counterResult(c)
+		return run != 0 && int64(run) == runs.Load()
+	})
+	idleRun, idleAt := counterResult(c)
+
+	// Touch wakes the sampler long before the idle interval is up, but the
+	// wake-up run only takes a baseline
+	baselineRun := runs.Load() + 1
+	touched := time.Now()
+	c.Touch("test")
+	waitFor(t, time.Second, "the baseline run", func() bool { return runs.Load() >= baselineRun })
+	if c.Idle() {
+		t.Error("still idle after Touch")
+	}
+	if run, at := counterResult(c); run != idleRun || !at.Equal(idleAt) {
+		t.Errorf("baseline run %d was published, want the idle-era run %d kept", run, idleRun)
+	}
+
+	// One interval later the next run is published
+	waitFor(t, 2*time.Second, "the first published run", func() bool {
+		run, _ := counterResult(c)
+		return run != idleRun
+	})
+	run, at := counterResult(c)
+	if run <= int(baselineRun) {
+		t.Errorf("published run %d, want a run after the baseline run %d", run, baselineRun)
+	}
+	if elapsed := at.Sub(touched); elapsed < interval*8/10 {
+		t.Errorf("published %v after Touch, want at least one interval (%v)", elapsed, interval)
+	}
+}
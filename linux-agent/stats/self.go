@@ -0,0 +1,181 @@
+package stats
+
+import (
+	"context"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBucketsMs are the upper bounds of the duration histogram buckets.
+var latencyBucketsMs = []float64{1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// histogram counts durations into latencyBucketsMs.
+type histogram struct {
+	counts []uint64 // One per bucket plus overflow
+	count  uint64
+	sumMs  float64
+	maxMs  float64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, len(latencyBucketsMs)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	ms := float64(d.Microseconds()) / 1000.0
+	i := sort.SearchFloat64s(latencyBucketsMs, ms)
+	h.counts[i]++
+	h.count++
+	h.sumMs += ms
+	if ms > h.maxMs {
+		h.maxMs = ms
+	}
+}
+
+func (h *histogram) stats() HistogramStats {
+	stats := HistogramStats{
+		Count:   h.count,
+		SumMs:   h.sumMs,
+		MaxMs:   h.maxMs,
+		Buckets: make([]HistogramBucket, len(latencyBucketsMs)),
+	}
+	var cumulative uint64
+	for i, le := range latencyBucketsMs {
+		cumulative += h.counts[i]
+		stats.Buckets[i] = HistogramBucket{LeMs: le, Count: cumulative}
+	}
+	return stats
+}
+
+type sourceMetrics struct {
+	runs     uint64
+	failures uint64
+	errors   uint64
+	last     time.Duration
+	duration *histogram
+}
+
+// selfMetrics records how long the agent spends collecting and serving.
+type selfMetrics struct {
+	mu        sync.Mutex
+	started   time.Time
+	sources   map[string]*sourceMetrics
+	endpoints map[string]*histogram
+}
+
+func newSelfMetrics() *selfMetrics {
+	return &selfMetrics{
+		started:   time.Now(),
+		sources:   make(map[string]*sourceMetrics),
+		endpoints: make(map[string]*histogram),
+	}
+}
+
+// recordRun records one source run. A run failed if it timed out without a
+// result; errors counts the errors it reported either way. Runs skipped
+// because the previous one is still blocked are not recorded.
+func (m *selfMetrics) recordRun(source string, d time.Duration, failed bool, errors int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sm, ok := m.sources[source]
+	if !ok {
+		sm = &sourceMetrics{duration: newHistogram()}
+		m.sources[source] = sm
+	}
+	sm.runs++
+	if failed {
+		sm.failures++
+	}
+	sm.errors += uint64(errors)
+	sm.last = d
+	sm.duration.observe(d)
+}
+
+// RecordRequest records the latency of a request to endpoint. Callers
+// should pass the route pattern, not the raw path, to keep the set of
+// endpoints bounded.
+func (c *Collector) RecordRequest(endpoint string, d time.Duration) {
+	m := c.self
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.endpoints[endpoint]
+	if !ok {
+		h = newHistogram()
+		m.endpoints[endpoint] = h
+	}
+	h.observe(d)
+}
+
+// LastDurations returns how long the latest run of each source took.
+func (c *Collector) LastDurations() map[string]time.Duration {
+	m := c.self
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	durations := make(map[string]time.Duration, len(m.sources))
+	for name, sm := range m.sources {
+		durations[name] = sm.last
+	}
+	return durations
+}
+
+// runtimeSamples are the Go runtime metrics reported in the self section.
+// runtime/metrics is read without stopping the world, unlike
+// runtime.ReadMemStats.
+var runtimeSamples = []string{
+	"/sched/goroutines:goroutines",
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/total:bytes",
+	"/gc/cycles/total:gc-cycles",
+}
+
+// collectSelf reports the agent's own resource usage and timings.
+func (c *Collector) collectSelf(ctx context.Context) *SelfStats {
+	samples := make([]metrics.Sample, len(runtimeSamples))
+	for i, name := range runtimeSamples {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+
+	value := func(i int) uint64 {
+		if samples[i].Value.Kind() != metrics.KindUint64 {
+			return 0
+		}
+		return samples[i].Value.Uint64()
+	}
+
+	m := c.self
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := &SelfStats{
+		UptimeSec:    time.Since(m.started).Seconds(),
+		Goroutines:   value(0),
+		HeapBytes:    value(1),
+		RuntimeBytes: value(2),
+		GCCycles:     value(3),
+		Collectors:   make([]CollectorTiming, 0, len(m.sources)),
+		Endpoints:    make([]EndpointTiming, 0, len(m.endpoints)),
+	}
+	for name, sm := range m.sources {
+		stats.Collectors = append(stats.Collectors, CollectorTiming{
+			Name:           name,
+			Runs:           sm.runs,
+			Failures:       sm.failures,
+			Errors:         sm.errors,
+			LastDurationMs: float64(sm.last.Microseconds()) / 1000.0,
+			Duration:       sm.duration.stats(),
+		})
+	}
+	sort.Slice(stats.Collectors, func(i, j int) bool { return stats.Collectors[i].Name < stats.Collectors[j].Name })
+	for endpoint, h := range m.endpoints {
+		stats.Endpoints = append(stats.Endpoints, EndpointTiming{Endpoint: endpoint, Latency: h.stats()})
+	}
+	sort.Slice(stats.Endpoints, func(i, j int) bool { return stats.Endpoints[i].Endpoint < stats.Endpoints[j].Endpoint })
+
+	return stats
+}
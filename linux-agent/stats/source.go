@@ -0,0 +1,177 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source is one family of metrics. Collect fills in its own section of the
+// snapshot and must not touch the others. Sources run concurrently with each
+// other; ctx carries the source's deadline and should be passed to anything
+// that can block.
+type Source interface {
+	Name() string
+	Enabled() bool
+	Interval() time.Duration
+	Collect(ctx context.Context, snapshot *RemoteLinuxStats)
+}
+
+// funcSource adapts a collect function to the Source interface.
+type funcSource struct {
+	name     string
+	enabled  bool
+	interval time.Duration
+	collect  func(ctx context.Context, snapshot *RemoteLinuxStats)
+}
+
+// NewSource creates an enabled Source from a collect function.
+func NewSource(name string, interval time.Duration, collect func(ctx context.Context, snapshot *RemoteLinuxStats)) Source {
+	return &funcSource{name: name, enabled: true, interval: interval, collect: collect}
+}
+
+func (s *funcSource) Name() string            { return s.name }
+func (s *funcSource) Enabled() bool           { return s.enabled }
+func (s *funcSource) Interval() time.Duration { return s.interval }
+
+func (s *funcSource) Collect(ctx context.Context, snapshot *RemoteLinuxStats) {
+	s.collect(ctx, snapshot)
+}
+
+// SourceInfo describes a registered source for /v1/capabilities.
+type SourceInfo struct {
+	Name       string `json:"name"`
+	Enabled    bool   `json:"enabled"`
+	IntervalMs int64  `json:"intervalMs"`
+}
+
+// Registry holds the sources the sampler iterates over, in registration
+// order, and per-source enable/disable and interval overrides from config.
+type Registry struct {
+	mu        sync.RWMutex
+	sources   []Source
+	overrides map[string]bool
+	intervals map[string]time.Duration
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		overrides: make(map[string]bool),
+		intervals: make(map[string]time.Duration),
+	}
+}
+
+// Register adds a source. Names must be unique.
+func (r *Registry) Register(source Source) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.sources {
+		if existing.Name() == source.Name() {
+			return fmt.Errorf("source %q already registered", source.Name())
+		}
+	}
+	r.sources = append(r.sources, source)
+	return nil
+}
+
+// SetEnabled overrides whether the named source runs.
+func (r *Registry) SetEnabled(name string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, source := range r.sources {
+		if source.Name() == name {
+			r.overrides[name] = enabled
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown collector %q (available: %s)", name, strings.Join(r.namesLocked(), ", "))
+}
+
+// SetInterval overrides how often the named source is collected.
+func (r *Registry) SetInterval(name string, interval time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if interval <= 0 {
+		return fmt.Errorf("interval for %q must be positive", name)
+	}
+	for _, source := range r.sources {
+		if source.Name() == name {
+			r.intervals[name] = interval
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown collector %q (available: %s)", name, strings.Join(r.namesLocked(), ", "))
+}
+
+// IntervalOf returns a source's collection interval, taking overrides into
+// account.
+func (r *Registry) IntervalOf(source Source) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if interval, ok := r.intervals[source.Name()]; ok {
+		return interval
+	}
+	return source.Interval()
+}
+
+// IsEnabled reports whether a source runs, taking overrides into account.
+func (r *Registry) IsEnabled(source Source) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if enabled, ok := r.overrides[source.Name()]; ok {
+		return enabled
+	}
+	return source.Enabled()
+}
+
+// Enabled returns the sources that should run, in registration order.
+func (r *Registry) Enabled() []Source {
+	var enabled []Source
+	for _, source := range r.Sources() {
+		if r.IsEnabled(source) {
+			enabled = append(enabled, source)
+		}
+	}
+	return enabled
+}
+
+// Sources returns every registered source, in registration order.
+func (r *Registry) Sources() []Source {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]Source(nil), r.sources...)
+}
+
+// Info describes every registered source, sorted by name.
+func (r *Registry) Info() []SourceInfo {
+	sources := r.Sources()
+	info := make([]SourceInfo, 0, len(sources))
+	for _, source := range sources {
+		info = append(info, SourceInfo{
+			Name:       source.Name(),
+			Enabled:    r.IsEnabled(source),
+			IntervalMs: r.IntervalOf(source).Milliseconds(),
+		})
+	}
+	sort.Slice(info, func(i, j int) bool { return info[i].Name < info[j].Name })
+	return info
+}
+
+func (r *Registry) namesLocked() []string {
+	names := make([]string, 0, len(r.sources))
+	for _, source := range r.sources {
+		names = append(names, source.Name())
+	}
+	sort.Strings(names)
+	return names
+}
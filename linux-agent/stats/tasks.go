@@ -0,0 +1,109 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procStat is the part of /proc/[pid]/stat the collectors use. Times are in
+// clock ticks; StartTime is ticks after boot.
+type procStat struct {
+	Comm       string
+	State      byte
+	PPID       int
+	UTime      uint64
+	STime      uint64
+	NumThreads int
+	StartTime  uint64
+	RSSPages   uint64
+}
+
+// parseProcStat parses /proc/[pid]/stat. The command name is in parentheses
+// and may itself contain spaces and parentheses, so the fields are split
+// after the last ')'.
+func parseProcStat(data string) (procStat, error) {
+	open := strings.IndexByte(data, '(')
+	end := strings.LastIndexByte(data, ')')
+	if open < 0 || end < open {
+		return procStat{}, errors.New("malformed stat")
+	}
+	// Fields after the command, starting with field 3 (state)
+	fields := strings.Fields(data[end+1:])
+	if len(fields) < 22 {
+		return procStat{}, errors.New("short stat")
+	}
+
+	ps := procStat{Comm: data[open+1 : end], State: fields[0][0]}
+	ps.PPID, _ = strconv.Atoi(fields[1])
+	ps.UTime, _ = strconv.ParseUint(fields[11], 10, 64)
+	ps.STime, _ = strconv.ParseUint(fields[12], 10, 64)
+	ps.NumThreads, _ = strconv.Atoi(fields[17])
+	ps.StartTime, _ = strconv.ParseUint(fields[19], 10, 64)
+	ps.RSSPages, _ = strconv.ParseUint(fields[21], 10, 64)
+	return ps, nil
+}
+
+// listPIDs returns the numeric entries of procPath.
+func (c *Collector) listPIDs() ([]int, error) {
+	entries, err := os.ReadDir(c.procPath)
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// collectTasks counts processes and threads by state. Processes that exit
+// while the table is walked are skipped. Kernel idle threads (state I)
+// count as sleeping, the way top shows them.
+func (c *Collector) collectTasks(ctx context.Context) *TaskStats {
+	stats := &TaskStats{Available: false}
+
+	pids, err := c.listPIDs()
+	if err != nil {
+		c.logError(ctx, "tasks", readErrorCode(err), fmt.Sprintf("failed to list %s: %v", c.procPath, err))
+		return stats
+	}
+
+	for _, pid := range pids {
+		if ctx.Err() != nil {
+			return stats
+		}
+		data, err := os.ReadFile(filepath.Join(c.procPath, strconv.Itoa(pid), "stat"))
+		if err != nil {
+			continue
+		}
+		ps, err := parseProcStat(string(data))
+		if err != nil {
+			continue
+		}
+
+		stats.Processes++
+		stats.Threads += ps.NumThreads
+		switch ps.State {
+		case 'R':
+			stats.Running++
+		case 'S', 'I':
+			stats.Sleeping++
+		case 'D':
+			stats.DiskSleep++
+		case 'Z':
+			stats.Zombie++
+		case 'T', 't':
+			stats.Stopped++
+		}
+	}
+
+	stats.Available = stats.Processes > 0
+	return stats
+}
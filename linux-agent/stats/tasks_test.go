@@ -0,0 +1,82 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseProcStat(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    procStat
+		wantErr bool
+	}{
+		{
+			name: "plain command",
+			data: "1234 (nginx) S 1 1234 1234 0 -1 4194560 1652 0 0 0 250 75 0 0 20 0 4 0 981234 123456789 2048 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 1 0 0 0 0 0\n",
+			want: procStat{Comm: "nginx", State: 'S', PPID: 1, UTime: 250, STime: 75, NumThreads: 4, StartTime: 981234, RSSPages: 2048},
+		},
+		{
+			name: "command with spaces and parentheses",
+			data: "77 (tmux: server (1)) R 1 77 77 0 -1 4194304 100 0 0 0 10 20 0 0 20 0 1 0 500 1000 300 18446744073709551615\n",
+			want: procStat{Comm: "tmux: server (1)", State: 'R', PPID: 1, UTime: 10, STime: 20, NumThreads: 1, StartTime: 500, RSSPages: 300},
+		},
+		{
+			name: "zombie",
+			data: "99 (defunct) Z 50 99 99 0 -1 4227084 0 0 0 0 3 1 0 0 20 0 1 0 7000 0 0 18446744073709551615\n",
+			want: procStat{Comm: "defunct", State: 'Z', PPID: 50, UTime: 3, STime: 1, NumThreads: 1, StartTime: 7000},
+		},
+		{
+			name: "kernel thread with empty command",
+			data: "2 () S 0 0 0 0 -1 2129984 0 0 0 0 0 0 0 0 20 0 1 0 1 0 0 18446744073709551615\n",
+			want: procStat{State: 'S', NumThreads: 1, StartTime: 1},
+		},
+		{
+			name:    "no command",
+			data:    "1234 nginx S 1 1234\n",
+			wantErr: true,
+		},
+		{
+			name:    "truncated",
+			data:    "1234 (nginx) S 1 1234 1234 0 -1\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			data:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProcStat(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProcStat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseProcStat() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTicksDuration(t *testing.T) {
+	tests := []struct {
+		ticks uint64
+		want  time.Duration
+	}{
+		{ticks: 0, want: 0},
+		{ticks: 1, want: 10 * time.Millisecond},
+		{ticks: 100, want: time.Second},
+		// Ten years of uptime overflows ticks * time.Second before dividing
+		{ticks: 10 * 365 * 24 * 3600 * userHZ, want: 10 * 365 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := ticksDuration(tt.ticks); got != tt.want {
+			t.Errorf("ticksDuration(%d) = %v, want %v", tt.ticks, got, tt.want)
+		}
+	}
+}
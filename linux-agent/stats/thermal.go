@@ -0,0 +1,90 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func (c *Collector) collectThermals(ctx context.Context) *ThermalStats {
+	stats := &ThermalStats{Available: false}
+
+	hwmonPath := filepath.Join(c.sysPath, "class/hwmon")
+	entries, err := os.ReadDir(hwmonPath)
+	if err != nil {
+		return stats
+	}
+
+	var sensors []ThermalSensor
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "hwmon") {
+			continue
+		}
+
+		hwmonDir := filepath.Join(hwmonPath, entry.Name())
+		tempFiles, _ := filepath.Glob(filepath.Join(hwmonDir, "temp*_input"))
+
+		for _, tempFile := range tempFiles {
+			data, err := os.ReadFile(tempFile)
+			if err != nil {
+				continue
+			}
+
+			tempMilliC, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+			if err != nil {
+				continue
+			}
+
+			tempC := float64(tempMilliC) / 1000.0
+			if tempC < -100 || tempC > 200 { // Sanity check
+				continue
+			}
+
+			// Extract sensor number from filename
+			baseName := filepath.Base(tempFile)
+			sensorNum := strings.TrimSuffix(strings.TrimPrefix(baseName, "temp"), "_input")
+
+			sensor := ThermalSensor{
+				Name:        fmt.Sprintf("%s_temp%s", entry.Name(), sensorNum),
+				TempCelsius: &tempC,
+			}
+
+			// Try to read label
+			labelFile := filepath.Join(hwmonDir, fmt.Sprintf("temp%s_label", sensorNum))
+			if labelData, err := os.ReadFile(labelFile); err == nil {
+				label := strings.TrimSpace(string(labelData))
+				sensor.Label = &label
+			}
+
+			// Try to read critical temp
+			critFile := filepath.Join(hwmonDir, fmt.Sprintf("temp%s_crit", sensorNum))
+			if critData, err := os.ReadFile(critFile); err == nil {
+				if critMilliC, err := strconv.ParseInt(strings.TrimSpace(string(critData)), 10, 64); err == nil {
+					critC := float64(critMilliC) / 1000.0
+					sensor.CriticalTemp = &critC
+				}
+			}
+
+			// Try to read max temp
+			maxFile := filepath.Join(hwmonDir, fmt.Sprintf("temp%s_max", sensorNum))
+			if maxData, err := os.ReadFile(maxFile); err == nil {
+				if maxMilliC, err := strconv.ParseInt(strings.TrimSpace(string(maxData)), 10, 64); err == nil {
+					maxC := float64(maxMilliC) / 1000.0
+					sensor.MaxTemp = &maxC
+				}
+			}
+
+			sensors = append(sensors, sensor)
+			stats.Available = true
+		}
+	}
+
+	if len(sensors) > 0 {
+		stats.Sensors = sensors
+	}
+
+	return stats
+}
@@ -2,18 +2,34 @@ package stats
 
 // RemoteLinuxStats matches the Swift DTO schema v1
 type RemoteLinuxStats struct {
-	Schema       string        `json:"schema"`
-	Timestamp    int64         `json:"timestamp"`
-	Hostname     string        `json:"hostname"`
-	AgentVersion string        `json:"agentVersion"`
-	CPU          *CPUStats     `json:"cpu,omitempty"`
-	Memory       *MemoryStats  `json:"memory,omitempty"`
-	Disk         *DiskStats    `json:"disk,omitempty"`
-	Network      *NetworkStats `json:"network,omitempty"`
-	Thermals     *ThermalStats `json:"thermals,omitempty"`
-	GPU          *GPUStats     `json:"gpu,omitempty"`
-	Features     *Features     `json:"features,omitempty"`
-	Errors       []string      `json:"errors,omitempty"`
+	Schema       string          `json:"schema"`
+	Timestamp    int64           `json:"timestamp"`
+	Hostname     string          `json:"hostname"`
+	AgentVersion string          `json:"agentVersion"`
+	CPU          *CPUStats       `json:"cpu,omitempty"`
+	Memory       *MemoryStats    `json:"memory,omitempty"`
+	Disk         *DiskStats      `json:"disk,omitempty"`
+	Network      *NetworkStats   `json:"network,omitempty"`
+	Thermals     *ThermalStats   `json:"thermals,omitempty"`
+	GPU          *GPUStats       `json:"gpu,omitempty"`
+	Features     *Features       `json:"features,omitempty"`
+	Pressure     *PressureStats  `json:"pressure,omitempty"`
+	Tasks        *TaskStats      `json:"tasks,omitempty"`
+	Interrupts   *InterruptStats `json:"interrupts,omitempty"`
+	Processes    *ProcessStats   `json:"processes,omitempty"`
+	Groups       *GroupStats     `json:"groups,omitempty"`
+	Watch        *WatchStats     `json:"watch,omitempty"`
+	Self         *SelfStats      `json:"self,omitempty"`
+	// Errors lists the active errors as "component: message" strings for
+	// older clients; each section carries the structured AgentErrors of
+	// the sources that fill it in.
+	Errors []string `json:"errors,omitempty"`
+	// CollectedAt maps each section to the unix time in milliseconds of
+	// the newest result it was built from; SourceCollectedAt maps each
+	// source to the time of its own result, for sections filled by
+	// several sources.
+	CollectedAt       map[string]int64 `json:"collectedAt,omitempty"`
+	SourceCollectedAt map[string]int64 `json:"sourceCollectedAt,omitempty"`
 }
 
 type CPUStats struct {
@@ -25,27 +41,308 @@ type CPUStats struct {
 	Loadavg5      *float64 `json:"loadavg5,omitempty"`
 	Loadavg15     *float64 `json:"loadavg15,omitempty"`
 	CoreCount     *int     `json:"coreCount,omitempty"`
+	// ProcsRunning is the number of runnable tasks, ProcsBlocked those
+	// waiting on I/O. The rates are since the previous sample.
+	ProcsRunning          *uint64  `json:"procsRunning,omitempty"`
+	ProcsBlocked          *uint64  `json:"procsBlocked,omitempty"`
+	ContextSwitchesPerSec *float64 `json:"contextSwitchesPerSec,omitempty"`
+	InterruptsPerSec      *float64 `json:"interruptsPerSec,omitempty"`
+	ForksPerSec           *float64 `json:"forksPerSec,omitempty"`
+	// Breakdown splits the time of all CPUs by state.
+	Breakdown *CPUBreakdown `json:"breakdown,omitempty"`
+	// Cores lists the online CPUs by ID. IDs can have gaps when CPUs are
+	// offline.
+	Cores []CPUCoreStats `json:"cores,omitempty"`
+	// Packages reports thermal throttling per physical CPU package, where
+	// the kernel exposes it (x86 thermal_throttle).
+	Packages []CPUPackageStats `json:"packages,omitempty"`
+	// CounterReset marks the first sample after the CPU counters went
+	// backwards (hotplug, reboot); percentages resume with the next one.
+	CounterReset bool         `json:"counterReset,omitempty"`
+	Errors       []AgentError `json:"errors,omitempty"`
+}
+
+// CPUBreakdown is the share of CPU time spent in each state. Guest time
+// (running VMs) is reported separately and not included in user and nice,
+// so the fields add up to 100.
+type CPUBreakdown struct {
+	UserPercent      float64 `json:"userPercent"`
+	NicePercent      float64 `json:"nicePercent"`
+	SystemPercent    float64 `json:"systemPercent"`
+	IrqPercent       float64 `json:"irqPercent"`
+	SoftirqPercent   float64 `json:"softirqPercent"`
+	StealPercent     float64 `json:"stealPercent"`
+	GuestPercent     float64 `json:"guestPercent"`
+	GuestNicePercent float64 `json:"guestNicePercent"`
+	IowaitPercent    float64 `json:"iowaitPercent"`
+	IdlePercent      float64 `json:"idlePercent"`
+}
+
+// CPUCoreStats is one online CPU (logical core). A core that just came
+// online has no percentages until its second sample. Frequencies are the
+// current value and the limits of the cpufreq policy; ThrottleCount is the
+// number of thermal throttle events since boot and ThrottleEvents those
+// since the previous sample.
+type CPUCoreStats struct {
+	ID              int      `json:"id"`
+	UsagePercent    *float64 `json:"usagePercent,omitempty"`
+	IowaitPercent   *float64 `json:"iowaitPercent,omitempty"`
+	StealPercent    *float64 `json:"stealPercent,omitempty"`
+	FrequencyMHz    *float64 `json:"frequencyMhz,omitempty"`
+	MinFrequencyMHz *float64 `json:"minFrequencyMhz,omitempty"`
+	MaxFrequencyMHz *float64 `json:"maxFrequencyMhz,omitempty"`
+	Governor        string   `json:"governor,omitempty"`
+	ThrottleCount   *uint64  `json:"throttleCount,omitempty"`
+	ThrottleEvents  *uint64  `json:"throttleEvents,omitempty"`
+	CounterReset    bool     `json:"counterReset,omitempty"`
+}
+
+// CPUPackageStats is the thermal throttling of one physical CPU package,
+// counted like CPUCoreStats.
+type CPUPackageStats struct {
+	ID             int     `json:"id"`
+	ThrottleCount  *uint64 `json:"throttleCount,omitempty"`
+	ThrottleEvents *uint64 `json:"throttleEvents,omitempty"`
+}
+
+// TaskStats counts the processes in the process table by state. Threads is
+// the sum of the processes' thread counts.
+type TaskStats struct {
+	Available bool         `json:"available"`
+	Processes int          `json:"processes"`
+	Threads   int          `json:"threads"`
+	Running   int          `json:"running"`
+	Sleeping  int          `json:"sleeping"`
+	DiskSleep int          `json:"diskSleep"`
+	Zombie    int          `json:"zombie"`
+	Stopped   int          `json:"stopped"`
+	Errors    []AgentError `json:"errors,omitempty"`
+}
+
+// ProcessStats summarizes the process sample. Count is the number of
+// processes sampled and Top the busiest by CPU; /v1/processes has the full
+// ranking.
+type ProcessStats struct {
+	Available bool          `json:"available"`
+	Count     int           `json:"count"`
+	Top       []ProcessInfo `json:"top,omitempty"`
+	Errors    []AgentError  `json:"errors,omitempty"`
+}
+
+// ProcessInfo is one process. CPUPercent is relative to one core, so a
+// multi-threaded process can exceed 100. PrivateBytes is resident minus
+// shared memory from statm, a cheap stand-in for PSS. The I/O rates need
+// access to the process's io file and are missing for other users'
+// processes when the agent runs unprivileged. StartTime is unix
+// milliseconds.
+type ProcessInfo struct {
+	PID              int      `json:"pid"`
+	PPID             int      `json:"ppid"`
+	Name             string   `json:"name"`
+	Cmdline          string   `json:"cmdline,omitempty"`
+	UID              *int     `json:"uid,omitempty"`
+	User             string   `json:"user,omitempty"`
+	State            string   `json:"state"`
+	Threads          int      `json:"threads"`
+	StartTime        int64    `json:"startTime,omitempty"`
+	CPUPercent       *float64 `json:"cpuPercent,omitempty"`
+	RSSBytes         uint64   `json:"rssBytes"`
+	PrivateBytes     *uint64  `json:"privateBytes,omitempty"`
+	ReadBytesPerSec  *float64 `json:"readBytesPerSec,omitempty"`
+	WriteBytesPerSec *float64 `json:"writeBytesPerSec,omitempty"`
+	Cgroup           string   `json:"cgroup,omitempty"`
+	ContainerID      string   `json:"containerId,omitempty"`
+}
+
+// GroupStats rolls the process sample up by owner, process name, systemd
+// unit and slice, and process tree. Each list is sorted by CPU, then
+// memory, and holds at most the 50 busiest groups.
+type GroupStats struct {
+	Available bool           `json:"available"`
+	Users     []ProcessGroup `json:"users,omitempty"`
+	Names     []ProcessGroup `json:"names,omitempty"`
+	Units     []ProcessGroup `json:"units,omitempty"`
+	Slices    []ProcessGroup `json:"slices,omitempty"`
+	Trees     []ProcessGroup `json:"trees,omitempty"`
+	Errors    []AgentError   `json:"errors,omitempty"`
+}
+
+// ProcessGroup is the combined usage of the processes sharing a key. For
+// process trees the key is the root's name and PID, as "smbd (2841)". The
+// I/O rates only count processes whose io file is readable.
+type ProcessGroup struct {
+	Key              string  `json:"key"`
+	Processes        int     `json:"processes"`
+	CPUPercent       float64 `json:"cpuPercent"`
+	RSSBytes         uint64  `json:"rssBytes"`
+	ReadBytesPerSec  float64 `json:"readBytesPerSec"`
+	WriteBytesPerSec float64 `json:"writeBytesPerSec"`
+}
+
+// WatchStats reports the processes of the watchlist. Missing counts the
+// entries that aren't running.
+type WatchStats struct {
+	Available bool             `json:"available"`
+	Processes []WatchedProcess `json:"processes,omitempty"`
+	Missing   int              `json:"missing"`
+	Errors    []AgentError     `json:"errors,omitempty"`
+}
+
+// WatchedProcess is one watchlist entry. StartTime and UptimeSec are those
+// of the oldest instance; Restarts counts the times it was replaced since
+// the agent started. Times are unix milliseconds.
+type WatchedProcess struct {
+	Name         string   `json:"name"`
+	State        string   `json:"state"`
+	Instances    int      `json:"instances"`
+	PIDs         []int    `json:"pids,omitempty"`
+	StartTime    *int64   `json:"startTime,omitempty"`
+	UptimeSec    *float64 `json:"uptimeSec,omitempty"`
+	Restarts     int      `json:"restarts"`
+	LastRestart  *int64   `json:"lastRestart,omitempty"`
+	MissingSince *int64   `json:"missingSince,omitempty"`
+}
+
+// InterruptStats is the rate of hardware interrupts and softirqs per CPU.
+// Every PerCPUPerSec array lines up with CPUs, the online CPU IDs. IRQs
+// lists only the interrupt sources that fired since the previous sample,
+// busiest first.
+type InterruptStats struct {
+	Available bool                  `json:"available"`
+	CPUs      []int                 `json:"cpus,omitempty"`
+	IRQs      []IRQStats            `json:"irqs,omitempty"`
+	Softirqs  []SoftirqStats        `json:"softirqs,omitempty"`
+	NICs      []NICInterruptSummary `json:"nics,omitempty"`
+	// Imbalanced is set when any NIC is.
+	Imbalanced   bool         `json:"imbalanced"`
+	CounterReset bool         `json:"counterReset,omitempty"`
+	Errors       []AgentError `json:"errors,omitempty"`
+}
+
+// IRQStats is one line of /proc/interrupts: a numbered IRQ with the devices
+// using it, or an architecture interrupt such as LOC with its description.
+// Interface is set for IRQs of a physical network interface.
+type IRQStats struct {
+	IRQ          string    `json:"irq"`
+	Device       string    `json:"device,omitempty"`
+	Interface    string    `json:"interface,omitempty"`
+	TotalPerSec  float64   `json:"totalPerSec"`
+	PerCPUPerSec []float64 `json:"perCpuPerSec"`
+}
+
+// SoftirqStats is one softirq type, such as NET_RX or BLOCK.
+type SoftirqStats struct {
+	Name         string    `json:"name"`
+	TotalPerSec  float64   `json:"totalPerSec"`
+	PerCPUPerSec []float64 `json:"perCpuPerSec"`
+}
+
+// NICInterruptSummary shows how one network interface's interrupts are
+// spread across CPUs. A NIC is imbalanced when a single CPU handles more
+// than the configured share of a significant interrupt rate.
+type NICInterruptSummary struct {
+	Interface     string  `json:"interface"`
+	IRQs          int     `json:"irqs"`
+	TotalPerSec   float64 `json:"totalPerSec"`
+	TopCPU        int     `json:"topCpu"`
+	TopCPUPercent float64 `json:"topCpuPercent"`
+	Imbalanced    bool    `json:"imbalanced"`
+}
+
+// PressureStats is Pressure Stall Information (PSI) for the host and the
+// cgroups configured with AGENT_PSI_CGROUPS.
+type PressureStats struct {
+	Available bool              `json:"available"`
+	CPU       *PressureResource `json:"cpu,omitempty"`
+	IO        *PressureResource `json:"io,omitempty"`
+	Memory    *PressureResource `json:"memory,omitempty"`
+	Cgroups   []CgroupPressure  `json:"cgroups,omitempty"`
+	Errors    []AgentError      `json:"errors,omitempty"`
+}
+
+// CgroupPressure is the PSI of one cgroup, by its path in the cgroup v2
+// hierarchy.
+type CgroupPressure struct {
+	Path   string            `json:"path"`
+	CPU    *PressureResource `json:"cpu,omitempty"`
+	IO     *PressureResource `json:"io,omitempty"`
+	Memory *PressureResource `json:"memory,omitempty"`
+}
+
+// PressureResource is the PSI of one resource. Some is the share of time at
+// least one task was stalled on it, Full the share all non-idle tasks were.
+type PressureResource struct {
+	Some *PressureLine `json:"some,omitempty"`
+	Full *PressureLine `json:"full,omitempty"`
+}
+
+// PressureLine is one line of a PSI file. The averages are percentages over
+// 10, 60 and 300 seconds; TotalUs is the stall time since boot and
+// StallUsPerSec its rate since the previous sample.
+type PressureLine struct {
+	Avg10         float64  `json:"avg10"`
+	Avg60         float64  `json:"avg60"`
+	Avg300        float64  `json:"avg300"`
+	TotalUs       uint64   `json:"totalUs"`
+	StallUsPerSec *float64 `json:"stallUsPerSec,omitempty"`
 }
 
 type MemoryStats struct {
-	Available      bool     `json:"available"`
-	TotalBytes     *uint64  `json:"totalBytes,omitempty"`
-	AvailableBytes *uint64  `json:"availableBytes,omitempty"`
-	UsedBytes      *uint64  `json:"usedBytes,omitempty"`
-	BuffersBytes   *uint64  `json:"buffersBytes,omitempty"`
-	CachedBytes    *uint64  `json:"cachedBytes,omitempty"`
-	SwapTotalBytes *uint64  `json:"swapTotalBytes,omitempty"`
-	SwapUsedBytes  *uint64  `json:"swapUsedBytes,omitempty"`
-	SwapCachedBytes *uint64 `json:"swapCachedBytes,omitempty"`
-	PsiMemAvg10    *float64 `json:"psiMemAvg10,omitempty"`
-	PsiMemAvg60    *float64 `json:"psiMemAvg60,omitempty"`
-	PsiMemAvg300   *float64 `json:"psiMemAvg300,omitempty"`
+	Available       bool     `json:"available"`
+	TotalBytes      *uint64  `json:"totalBytes,omitempty"`
+	AvailableBytes  *uint64  `json:"availableBytes,omitempty"`
+	UsedBytes       *uint64  `json:"usedBytes,omitempty"`
+	BuffersBytes    *uint64  `json:"buffersBytes,omitempty"`
+	CachedBytes     *uint64  `json:"cachedBytes,omitempty"`
+	SwapTotalBytes  *uint64  `json:"swapTotalBytes,omitempty"`
+	SwapUsedBytes   *uint64  `json:"swapUsedBytes,omitempty"`
+	SwapCachedBytes *uint64  `json:"swapCachedBytes,omitempty"`
+	PsiMemAvg10     *float64 `json:"psiMemAvg10,omitempty"`
+	PsiMemAvg60     *float64 `json:"psiMemAvg60,omitempty"`
+	PsiMemAvg300    *float64 `json:"psiMemAvg300,omitempty"`
+	// EffectiveUsedBytes is UsedBytes less the reclaimable part of the
+	// ZFS ARC, which the kernel counts as used although ZFS frees it under
+	// memory pressure, like page cache. Without ZFS it equals UsedBytes.
+	EffectiveUsedBytes *uint64      `json:"effectiveUsedBytes,omitempty"`
+	ZFSARC             *ZFSARCStats `json:"zfsArc,omitempty"`
+	Errors             []AgentError `json:"errors,omitempty"`
+}
+
+// ZFSARCStats is the ZFS adaptive replacement cache. TargetBytes is the
+// size the ARC is currently aiming for; it shrinks towards MinBytes under
+// memory pressure. ReclaimableBytes is the part of the ARC above MinBytes.
+// Rates and hit ratios are since the previous sample.
+type ZFSARCStats struct {
+	SizeBytes        uint64         `json:"sizeBytes"`
+	TargetBytes      uint64         `json:"targetBytes"`
+	MinBytes         uint64         `json:"minBytes"`
+	MaxBytes         uint64         `json:"maxBytes"`
+	ReclaimableBytes uint64         `json:"reclaimableBytes"`
+	HitsPerSec       *float64       `json:"hitsPerSec,omitempty"`
+	MissesPerSec     *float64       `json:"missesPerSec,omitempty"`
+	HitRatioPercent  *float64       `json:"hitRatioPercent,omitempty"`
+	L2               *ZFSL2ARCStats `json:"l2,omitempty"`
+}
+
+// ZFSL2ARCStats is the second-level ARC on cache devices. SizeBytes is the
+// cached data before compression, AllocatedBytes the space it takes on the
+// devices and HeaderBytes the ARC memory spent indexing it.
+type ZFSL2ARCStats struct {
+	SizeBytes        uint64   `json:"sizeBytes"`
+	AllocatedBytes   uint64   `json:"allocatedBytes"`
+	HeaderBytes      uint64   `json:"headerBytes"`
+	HitsPerSec       *float64 `json:"hitsPerSec,omitempty"`
+	MissesPerSec     *float64 `json:"missesPerSec,omitempty"`
+	HitRatioPercent  *float64 `json:"hitRatioPercent,omitempty"`
+	ReadBytesPerSec  *float64 `json:"readBytesPerSec,omitempty"`
+	WriteBytesPerSec *float64 `json:"writeBytesPerSec,omitempty"`
 }
 
 type DiskStats struct {
-	Available   bool          `json:"available"`
-	Devices     []DiskDevice  `json:"devices,omitempty"`
-	Filesystems []Filesystem  `json:"filesystems,omitempty"`
+	Available   bool         `json:"available"`
+	Devices     []DiskDevice `json:"devices,omitempty"`
+	Filesystems []Filesystem `json:"filesystems,omitempty"`
+	Errors      []AgentError `json:"errors,omitempty"`
 }
 
 type DiskDevice struct {
@@ -54,22 +351,26 @@ type DiskDevice struct {
 	WriteBytesPerSec *float64 `json:"writeBytesPerSec,omitempty"`
 	ReadsPerSec      *float64 `json:"readsPerSec,omitempty"`
 	WritesPerSec     *float64 `json:"writesPerSec,omitempty"`
+	// CounterReset marks the first sample after the device's counters
+	// were reset (hot-swap, reboot); rates resume with the next one.
+	CounterReset bool `json:"counterReset,omitempty"`
 }
 
 type Filesystem struct {
-	MountPoint     string  `json:"mountPoint"`
-	Device         string  `json:"device"`
-	FsType         *string `json:"fsType,omitempty"`
-	TotalBytes     *uint64 `json:"totalBytes,omitempty"`
-	UsedBytes      *uint64 `json:"usedBytes,omitempty"`
-	AvailableBytes *uint64 `json:"availableBytes,omitempty"`
+	MountPoint     string   `json:"mountPoint"`
+	Device         string   `json:"device"`
+	FsType         *string  `json:"fsType,omitempty"`
+	TotalBytes     *uint64  `json:"totalBytes,omitempty"`
+	UsedBytes      *uint64  `json:"usedBytes,omitempty"`
+	AvailableBytes *uint64  `json:"availableBytes,omitempty"`
 	UsagePercent   *float64 `json:"usagePercent,omitempty"`
 }
 
 type NetworkStats struct {
-	Available       bool               `json:"available"`
-	Interfaces      []NetworkInterface `json:"interfaces,omitempty"`
-	ExternalIPv4    *string            `json:"externalIpv4,omitempty"`
+	Available    bool               `json:"available"`
+	Interfaces   []NetworkInterface `json:"interfaces,omitempty"`
+	ExternalIPv4 *string            `json:"externalIpv4,omitempty"`
+	Errors       []AgentError       `json:"errors,omitempty"`
 }
 
 type NetworkInterface struct {
@@ -79,11 +380,16 @@ type NetworkInterface struct {
 	Ipv4Address   *string  `json:"ipv4Address,omitempty"`
 	Ipv6Address   *string  `json:"ipv6Address,omitempty"`
 	MacAddress    *string  `json:"macAddress,omitempty"`
+	// CounterReset marks the first sample after the interface's counters
+	// were reset (interface recreated, reboot); rates resume with the next
+	// one.
+	CounterReset bool `json:"counterReset,omitempty"`
 }
 
 type ThermalStats struct {
 	Available bool            `json:"available"`
 	Sensors   []ThermalSensor `json:"sensors,omitempty"`
+	Errors    []AgentError    `json:"errors,omitempty"`
 }
 
 type ThermalSensor struct {
@@ -95,8 +401,9 @@ type ThermalSensor struct {
 }
 
 type GPUStats struct {
-	Available bool        `json:"available"`
-	Devices   []GPUDevice `json:"devices,omitempty"`
+	Available bool         `json:"available"`
+	Devices   []GPUDevice  `json:"devices,omitempty"`
+	Errors    []AgentError `json:"errors,omitempty"`
 }
 
 type GPUDevice struct {
@@ -108,8 +415,52 @@ type GPUDevice struct {
 }
 
 type Features struct {
-	SmartAvailable   *bool `json:"smartAvailable,omitempty"`
-	NvmeAvailable    *bool `json:"nvmeAvailable,omitempty"`
-	ThermalAvailable *bool `json:"thermalAvailable,omitempty"`
-	GpuAvailable     *bool `json:"gpuAvailable,omitempty"`
+	SmartAvailable   *bool        `json:"smartAvailable,omitempty"`
+	NvmeAvailable    *bool        `json:"nvmeAvailable,omitempty"`
+	ThermalAvailable *bool        `json:"thermalAvailable,omitempty"`
+	GpuAvailable     *bool        `json:"gpuAvailable,omitempty"`
+	Errors           []AgentError `json:"errors,omitempty"`
+}
+
+// SelfStats describes the agent itself: Go runtime usage and how long
+// collectors and requests take. Durations are in milliseconds.
+type SelfStats struct {
+	UptimeSec    float64           `json:"uptimeSec"`
+	Goroutines   uint64            `json:"goroutines"`
+	HeapBytes    uint64            `json:"heapBytes"`
+	RuntimeBytes uint64            `json:"runtimeBytes"`
+	GCCycles     uint64            `json:"gcCycles"`
+	Collectors   []CollectorTiming `json:"collectors"`
+	Endpoints    []EndpointTiming  `json:"endpoints"`
+}
+
+// CollectorTiming counts one source's runs. Failures are runs that timed
+// out; Errors counts every error reported.
+type CollectorTiming struct {
+	Name           string         `json:"name"`
+	Runs           uint64         `json:"runs"`
+	Failures       uint64         `json:"failures"`
+	Errors         uint64         `json:"errors"`
+	LastDurationMs float64        `json:"lastDurationMs"`
+	Duration       HistogramStats `json:"duration"`
+}
+
+type EndpointTiming struct {
+	Endpoint string         `json:"endpoint"`
+	Latency  HistogramStats `json:"latency"`
+}
+
+// HistogramStats is a duration histogram with cumulative buckets: each
+// bucket counts observations less than or equal to LeMs. Count includes
+// observations above the last bucket.
+type HistogramStats struct {
+	Count   uint64            `json:"count"`
+	SumMs   float64           `json:"sumMs"`
+	MaxMs   float64           `json:"maxMs"`
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+type HistogramBucket struct {
+	LeMs  float64 `json:"leMs"`
+	Count uint64  `json:"count"`
 }
@@ -0,0 +1,328 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Watched process states.
+const (
+	WatchRunning = "running"
+	WatchMissing = "missing"
+	// WatchUnknown means the pidfile couldn't be read, so whether the
+	// process runs is not known.
+	WatchUnknown = "unknown"
+)
+
+// pidfileSlack allows for the boot time in /proc/stat being rounded to the
+// second when checking a pidfile against its process's start time.
+const pidfileSlack = 2 * time.Second
+
+// WatchRule selects the processes of one watchlist entry. Exactly one of
+// Process, Cmdline and Pidfile is set.
+type WatchRule struct {
+	// Name labels the entry in the watch section and in errors.
+	Name string `json:"name"`
+	// Process matches the process name (comm) exactly. The kernel
+	// truncates it to 15 characters.
+	Process string `json:"process,omitempty"`
+	// Cmdline is a regular expression matched against the command line,
+	// with arguments separated by spaces.
+	Cmdline string `json:"cmdline,omitempty"`
+	// Pidfile is the path of a file holding the PID of the process.
+	Pidfile string `json:"pidfile,omitempty"`
+}
+
+// WatchFile is the content of AGENT_WATCH_FILE.
+type WatchFile struct {
+	Watch []WatchRule `json:"watch"`
+}
+
+// LoadWatchFile reads watchlist entries from a JSON file of the form
+// {"watch": [{"name": "smbd", "process": "smbd"}]}. Entries that only set
+// process are named after it.
+func LoadWatchFile(path string) ([]WatchRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file WatchFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i := range file.Watch {
+		if file.Watch[i].Name == "" {
+			file.Watch[i].Name = file.Watch[i].Process
+		}
+	}
+	return file.Watch, nil
+}
+
+// ValidateWatchRules checks that every entry has a unique name, exactly one
+// way of matching, a valid regular expression and an absolute pidfile.
+func ValidateWatchRules(rules []WatchRule) error {
+	seen := make(map[string]bool)
+	for i, r := range rules {
+		if r.Name == "" {
+			return fmt.Errorf("watch entry #%d has no name", i+1)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("duplicate watch entry %q", r.Name)
+		}
+		seen[r.Name] = true
+
+		matchers := 0
+		for _, m := range []string{r.Process, r.Cmdline, r.Pidfile} {
+			if m != "" {
+				matchers++
+			}
+		}
+		if matchers != 1 {
+			return fmt.Errorf("watch entry %q needs exactly one of process, cmdline and pidfile", r.Name)
+		}
+		if r.Cmdline != "" {
+			if _, err := regexp.Compile(r.Cmdline); err != nil {
+				return fmt.Errorf("watch entry %q: %w", r.Name, err)
+			}
+		}
+		if r.Pidfile != "" && !filepath.IsAbs(r.Pidfile) {
+			return fmt.Errorf("watch entry %q: pidfile must be an absolute path", r.Name)
+		}
+	}
+	return nil
+}
+
+// watcher is a watchlist entry and what the watch source remembers about
+// it between runs.
+type watcher struct {
+	rule         WatchRule
+	cmdline      *regexp.Regexp
+	mainKey      string // processKey of the oldest instance last seen
+	restarts     int
+	lastRestart  time.Time
+	missingSince time.Time
+}
+
+// newWatchers compiles rules, skipping any that don't validate.
+func newWatchers(rules []WatchRule) ([]*watcher, error) {
+	var watchers []*watcher
+	var errs []error
+	for _, r := range rules {
+		if err := ValidateWatchRules([]WatchRule{r}); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		w := &watcher{rule: r}
+		if r.Cmdline != "" {
+			w.cmdline = regexp.MustCompile(r.Cmdline)
+		}
+		watchers = append(watchers, w)
+	}
+	return watchers, errors.Join(errs...)
+}
+
+// watchInstance is a running process matched by a watcher.
+type watchInstance struct {
+	pid   int
+	start uint64 // Clock ticks after boot
+}
+
+// collectWatch checks every watchlist entry against the process table.
+// A restart is the oldest matching instance being replaced, whether or not
+// the entry was missing in between, so worker processes coming and going
+// under a long-lived parent don't count. An entry that isn't running is
+// reported as an error until it is back.
+func (c *Collector) collectWatch(ctx context.Context) *WatchStats {
+	stats := &WatchStats{Available: false}
+	if len(c.watchers) == 0 {
+		return stats
+	}
+	now := time.Now()
+
+	byComm := make(map[string][]watchInstance)
+	var byCmdline []*watcher
+	for _, w := range c.watchers {
+		switch {
+		case w.cmdline != nil:
+			byCmdline = append(byCmdline, w)
+		case w.rule.Process != "":
+			byComm[w.rule.Process] = nil
+		}
+	}
+
+	pids, err := c.listPIDs()
+	if err != nil {
+		c.logError(ctx, "watch", readErrorCode(err), fmt.Sprintf("failed to list %s: %v", c.procPath, err))
+		return stats
+	}
+
+	table := make(map[int]procStat, len(pids))
+	matched := make(map[*watcher][]watchInstance)
+	cmdlines := make(map[string]string, len(c.watchCmdlines))
+	for _, pid := range pids {
+		if ctx.Err() != nil {
+			return stats
+		}
+		dir := filepath.Join(c.procPath, strconv.Itoa(pid))
+		data, err := os.ReadFile(filepath.Join(dir, "stat"))
+		if err != nil {
+			continue
+		}
+		ps, err := parseProcStat(string(data))
+		if err != nil || ps.State == 'Z' {
+			continue
+		}
+		table[pid] = ps
+		instance := watchInstance{pid: pid, start: ps.StartTime}
+
+		if _, ok := byComm[ps.Comm]; ok {
+			byComm[ps.Comm] = append(byComm[ps.Comm], instance)
+		}
+		if len(byCmdline) == 0 {
+			continue
+		}
+		// Command lines are cached for the life of the process
+		key := processKey(pid, ps.StartTime)
+		cmdline, ok := c.watchCmdlines[key]
+		if !ok {
+			cmdline = readCmdline(dir, ps.Comm)
+		}
+		cmdlines[key] = cmdline
+		for _, w := range byCmdline {
+			if w.cmdline.MatchString(cmdline) {
+				matched[w] = append(matched[w], instance)
+			}
+		}
+	}
+	c.watchCmdlines = cmdlines
+	stats.Available = true
+
+	boot, haveBoot := c.bootTime()
+	for _, w := range c.watchers {
+		wp := WatchedProcess{Name: w.rule.Name, State: WatchMissing}
+		var instances []watchInstance
+		switch {
+		case w.cmdline != nil:
+			instances = matched[w]
+		case w.rule.Pidfile != "":
+			instance, err := c.readPidfile(w.rule.Pidfile, table, boot, haveBoot)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				wp.State = WatchUnknown
+				c.logError(ctx, "watch", readErrorCode(err), fmt.Sprintf("%s: failed to read pidfile: %v", w.rule.Name, err))
+			}
+			if instance != nil {
+				instances = []watchInstance{*instance}
+			}
+		default:
+			instances = byComm[w.rule.Process]
+		}
+
+		if len(instances) > 0 {
+			c.watchRunning(w, &wp, instances, now, boot, haveBoot)
+		} else if wp.State == WatchMissing {
+			if w.missingSince.IsZero() {
+				w.missingSince = now
+				c.log.Warn("watched process missing", "name", w.rule.Name, "restarts", w.restarts)
+			}
+			missingSince := w.missingSince.UnixMilli()
+			wp.MissingSince = &missingSince
+			stats.Missing++
+			c.logError(ctx, "watch", ErrCodeProcessMissing, w.rule.Name+" is not running")
+		}
+
+		wp.Restarts = w.restarts
+		if !w.lastRestart.IsZero() {
+			lastRestart := w.lastRestart.UnixMilli()
+			wp.LastRestart = &lastRestart
+		}
+		stats.Processes = append(stats.Processes, wp)
+	}
+	return stats
+}
+
+// watchRunning fills in a running entry and counts a restart when its
+// oldest instance changed since the previous run.
+func (c *Collector) watchRunning(w *watcher, wp *WatchedProcess, instances []watchInstance, now, boot time.Time, haveBoot bool) {
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].start != instances[j].start {
+			return instances[i].start < instances[j].start
+		}
+		return instances[i].pid < instances[j].pid
+	})
+	oldest := instances[0]
+
+	wp.State = WatchRunning
+	wp.Instances = len(instances)
+	for _, instance := range instances {
+		wp.PIDs = append(wp.PIDs, instance.pid)
+	}
+	if haveBoot {
+		started := boot.Add(ticksDuration(oldest.start))
+		startTime := started.UnixMilli()
+		uptime := now.Sub(started).Seconds()
+		wp.StartTime = &startTime
+		wp.UptimeSec = &uptime
+	}
+
+	key := processKey(oldest.pid, oldest.start)
+	if w.mainKey != "" && w.mainKey != key {
+		w.restarts++
+		w.lastRestart = now
+		c.log.Warn("watched process restarted", "name", w.rule.Name, "pid", oldest.pid, "restarts", w.restarts)
+	}
+	if !w.missingSince.IsZero() {
+		c.log.Info("watched process running again", "name", w.rule.Name, "pid", oldest.pid,
+			"after", now.Sub(w.missingSince).Round(time.Second).String())
+	}
+	w.mainKey = key
+	w.missingSince = time.Time{}
+}
+
+// readPidfile returns the process named by a pidfile. A PID that isn't
+// running, or that belongs to a process started after the pidfile was
+// written (the PID was reused), means the pidfile is stale and there is no
+// instance.
+func (c *Collector) readPidfile(path string, table map[int]procStat, boot time.Time, haveBoot bool) (*watchInstance, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return nil, fmt.Errorf("%s does not hold a PID", path)
+	}
+	ps, ok := table[pid]
+	if !ok {
+		return nil, nil
+	}
+	if haveBoot {
+		started := boot.Add(ticksDuration(ps.StartTime))
+		if started.After(info.ModTime().Add(pidfileSlack)) {
+			return nil, nil
+		}
+	}
+	return &watchInstance{pid: pid, start: ps.StartTime}, nil
+}
+
+// probeWatch checks that there is a watchlist and the process table can be
+// listed.
+func (c *Collector) probeWatch() Capability {
+	if len(c.watchers) == 0 {
+		return Capability{Code: ReasonNotConfigured, Reason: "no watchlist configured (AGENT_WATCH, AGENT_WATCH_FILE)"}
+	}
+	return c.probeProcessTable()
+}
@@ -0,0 +1,281 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateWatchRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []WatchRule
+		wantErr string
+	}{
+		{name: "empty", rules: nil},
+		{
+			name: "one of each matcher",
+			rules: []WatchRule{
+				{Name: "smbd", Process: "smbd"},
+				{Name: "backup", Cmdline: `rsync .* /mnt/backup`},
+				{Name: "nginx", Pidfile: "/run/nginx.pid"},
+			},
+		},
+		{name: "no name", rules: []WatchRule{{Process: "smbd"}}, wantErr: "has no name"},
+		{
+			name:    "duplicate name",
+			rules:   []WatchRule{{Name: "smbd", Process: "smbd"}, {Name: "smbd", Process: "nmbd"}},
+			wantErr: "duplicate",
+		},
+		{name: "no matcher", rules: []WatchRule{{Name: "smbd"}}, wantErr: "exactly one"},
+		{
+			name:    "two matchers",
+			rules:   []WatchRule{{Name: "smbd", Process: "smbd", Pidfile: "/run/smbd.pid"}},
+			wantErr: "exactly one",
+		},
+		{name: "bad regexp", rules: []WatchRule{{Name: "x", Cmdline: "("}}, wantErr: "missing closing )"},
+		{name: "relative pidfile", rules: []WatchRule{{Name: "x", Pidfile: "run/x.pid"}}, wantErr: "absolute"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateWatchRules(tt.rules)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("ValidateWatchRules() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("ValidateWatchRules() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watch.json")
+	data := `{"watch": [{"process": "smbd"}, {"name": "backup", "cmdline": "rsync"}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadWatchFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WatchRule{{Name: "smbd", Process: "smbd"}, {Name: "backup", Cmdline: "rsync"}}
+	if fmt.Sprint(rules) != fmt.Sprint(want) {
+		t.Errorf("LoadWatchFile() = %+v, want %+v", rules, want)
+	}
+}
+
+// fakeProc is a process in a fake /proc.
+type fakeProc struct {
+	pid     int
+	comm    string
+	cmdline string
+	start   uint64 // Ticks after boot
+	state   string
+}
+
+const fakeBootTime = 1_700_000_000
+
+func writeFakeProc(t *testing.T, dir string, procs []fakeProc) {
+	t.Helper()
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err == nil {
+			os.RemoveAll(filepath.Join(dir, entry.Name()))
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(fmt.Sprintf("cpu 1 2 3 4\nbtime %d\n", fakeBootTime)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range procs {
+		pidDir := filepath.Join(dir, strconv.Itoa(p.pid))
+		if err := os.MkdirAll(pidDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		state := p.state
+		if state == "" {
+			state = "S"
+		}
+		stat := fmt.Sprintf("%d (%s) %s 1 %d %d 0 -1 0 0 0 0 0 10 5 0 0 20 0 1 0 %d 1000 100\n", p.pid, p.comm, state, p.pid, p.pid, p.start)
+		if err := os.WriteFile(filepath.Join(pidDir, "stat"), []byte(stat), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cmdline := strings.ReplaceAll(p.cmdline, " ", "\x00")
+		if err := os.WriteFile(filepath.Join(pidDir, "cmdline"), []byte(cmdline), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCollectWatch(t *testing.T) {
+	type step struct {
+		procs         []fakeProc
+		wantState     string
+		wantInstances int
+		wantRestarts  int
+		wantEvents    []string
+	}
+	tests := []struct {
+		name  string
+		rule  WatchRule
+		steps []step
+	}{
+		{
+			name: "process running then missing then back",
+			rule: WatchRule{Name: "smbd", Process: "smbd"},
+			steps: []step{
+				{procs: []fakeProc{{pid: 10, comm: "smbd", start: 100}}, wantState: WatchRunning, wantInstances: 1},
+				{procs: nil, wantState: WatchMissing, wantEvents: []string{"watched process missing"}},
+				{
+					procs:     []fakeProc{{pid: 20, comm: "smbd", start: 900}},
+					wantState: WatchRunning, wantInstances: 1, wantRestarts: 1,
+					wantEvents: []string{"watched process restarted", "watched process running again"},
+				},
+			},
+		},
+		{
+			name: "workers coming and going are not restarts",
+			rule: WatchRule{Name: "smbd", Process: "smbd"},
+			steps: []step{
+				{procs: []fakeProc{{pid: 10, comm: "smbd", start: 100}, {pid: 11, comm: "smbd", start: 200}}, wantState: WatchRunning, wantInstances: 2},
+				{procs: []fakeProc{{pid: 10, comm: "smbd", start: 100}, {pid: 12, comm: "smbd", start: 300}}, wantState: WatchRunning, wantInstances: 2},
+				{procs: []fakeProc{{pid: 10, comm: "smbd", start: 100}}, wantState: WatchRunning, wantInstances: 1},
+			},
+		},
+		{
+			name: "restart without a gap",
+			rule: WatchRule{Name: "smbd", Process: "smbd"},
+			steps: []step{
+				{procs: []fakeProc{{pid: 10, comm: "smbd", start: 100}}, wantState: WatchRunning, wantInstances: 1},
+				{
+					procs:     []fakeProc{{pid: 30, comm: "smbd", start: 500}},
+					wantState: WatchRunning, wantInstances: 1, wantRestarts: 1,
+					wantEvents: []string{"watched process restarted"},
+				},
+			},
+		},
+		{
+			name: "zombies don't count",
+			rule: WatchRule{Name: "smbd", Process: "smbd"},
+			steps: []step{
+				{procs: []fakeProc{{pid: 10, comm: "smbd", start: 100, state: "Z"}}, wantState: WatchMissing, wantEvents: []string{"watched process missing"}},
+			},
+		},
+		{
+			name: "cmdline regexp",
+			rule: WatchRule{Name: "backup", Cmdline: `^rsync .*/mnt/backup`},
+			steps: []step{
+				{procs: []fakeProc{{pid: 10, comm: "rsync", cmdline: "rsync -a /data /mnt/other", start: 100}}, wantState: WatchMissing, wantEvents: []string{"watched process missing"}},
+				{
+					procs:     []fakeProc{{pid: 11, comm: "rsync", cmdline: "rsync -a /data /mnt/backup", start: 200}},
+					wantState: WatchRunning, wantInstances: 1,
+					wantEvents: []string{"watched process running again"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := t.TempDir()
+			c := NewCollector(Options{Interval: time.Second, Watchlist: []WatchRule{tt.rule}})
+			c.procPath = proc
+			var events bytes.Buffer
+			c.log = slog.New(slog.NewJSONHandler(&events, nil))
+
+			for i, s := range tt.steps {
+				writeFakeProc(t, proc, s.procs)
+				events.Reset()
+				stats := c.collectWatch(context.Background())
+				if got := logMessages(t, &events); fmt.Sprint(got) != fmt.Sprint(s.wantEvents) {
+					t.Errorf("step %d: events %q, want %q", i+1, got, s.wantEvents)
+				}
+				if !stats.Available || len(stats.Processes) != 1 {
+					t.Fatalf("step %d: got %+v", i+1, stats)
+				}
+				wp := stats.Processes[0]
+				if wp.State != s.wantState || wp.Instances != s.wantInstances || wp.Restarts != s.wantRestarts {
+					t.Errorf("step %d: state %s, %d instances, %d restarts; want %s, %d, %d",
+						i+1, wp.State, wp.Instances, wp.Restarts, s.wantState, s.wantInstances, s.wantRestarts)
+				}
+				if (wp.State == WatchMissing) != (wp.MissingSince != nil) {
+					t.Errorf("step %d: state %s with missingSince %v", i+1, wp.State, wp.MissingSince)
+				}
+				if wp.State == WatchRunning && (wp.StartTime == nil || wp.UptimeSec == nil) {
+					t.Errorf("step %d: running without start time or uptime", i+1)
+				}
+			}
+		})
+	}
+}
+
+// logMessages returns the messages of the JSON log records in buf.
+func logMessages(t *testing.T, buf *bytes.Buffer) []string {
+	t.Helper()
+	var msgs []string
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var record struct{ Msg string }
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, record.Msg)
+	}
+	return msgs
+}
+
+func TestReadPidfile(t *testing.T) {
+	boot := time.Unix(fakeBootTime, 0)
+	table := map[int]procStat{
+		10: {Comm: "nginx", StartTime: 100 * userHZ}, // Started at boot+100s
+	}
+	tests := []struct {
+		name     string
+		content  string
+		modAfter time.Duration // Pidfile mtime after boot
+		want     *watchInstance
+		wantErr  bool
+	}{
+		{name: "running", content: "10\n", modAfter: 101 * time.Second, want: &watchInstance{pid: 10, start: 100 * userHZ}},
+		{name: "written just before the start was rounded", content: "10", modAfter: 99 * time.Second, want: &watchInstance{pid: 10, start: 100 * userHZ}},
+		{name: "pid reused after the pidfile was written", content: "10", modAfter: 50 * time.Second},
+		{name: "not running", content: "11", modAfter: 101 * time.Second},
+		{name: "not a pid", content: "nginx", modAfter: 101 * time.Second, wantErr: true},
+		{name: "zero", content: "0", modAfter: 101 * time.Second, wantErr: true},
+	}
+
+	c := &Collector{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "x.pid")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			mtime := boot.Add(tt.modAfter)
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := c.readPidfile(path, table, boot, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readPidfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("readPidfile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := c.readPidfile(filepath.Join(t.TempDir(), "missing.pid"), table, boot, true); !os.IsNotExist(err) {
+		t.Errorf("missing pidfile: error = %v, want not exist", err)
+	}
+}
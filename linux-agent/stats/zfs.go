@@ -0,0 +1,100 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseARCStats parses the kstat file /proc/spl/kstat/zfs/arcstats:
+//
+//	13 1 0x01 123 33456 4512315432 80233567890
+//	name                            type data
+//	hits                            4    1838417
+//	...
+//
+// Signed (type 3) values such as memory_available_bytes can be negative and
+// are skipped.
+func parseARCStats(data string) map[string]uint64 {
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[2], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+	return values
+}
+
+// hitRatio turns hit and miss counters into rates and the share of hits
+// since the previous sample. The ratio is nil on the first sample and when
+// there were no lookups.
+func (c *Collector) hitRatio(prefix string, hits, misses uint64, now time.Time, bootID string) (hitsPerSec, missesPerSec, percent *float64) {
+	hitsPerSec, _ = c.arcCounters.rate(prefix+"hits", hits, now, bootID)
+	missesPerSec, _ = c.arcCounters.rate(prefix+"misses", misses, now, bootID)
+	if hitsPerSec != nil && missesPerSec != nil && *hitsPerSec+*missesPerSec > 0 {
+		ratio := *hitsPerSec / (*hitsPerSec + *missesPerSec) * 100.0
+		percent = &ratio
+	}
+	return hitsPerSec, missesPerSec, percent
+}
+
+// collectARC reads the ZFS ARC statistics. Hosts without ZFS have no
+// arcstats file and get nil without an error.
+//
+// The ARC is kernel memory the kernel doesn't count as page cache, so
+// MemAvailable leaves it out even though ZFS gives it back under memory
+// pressure, down to c_min. The part above c_min is reported as
+// reclaimable.
+func (c *Collector) collectARC(ctx context.Context) *ZFSARCStats {
+	path := filepath.Join(c.procPath, "spl/kstat/zfs/arcstats")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.logError(ctx, "memory", readErrorCode(err), fmt.Sprintf("failed to read %s: %v", path, err))
+		}
+		return nil
+	}
+	values := parseARCStats(string(data))
+	size, ok := values["size"]
+	if !ok {
+		c.logError(ctx, "memory", ErrCodeReadFailed, fmt.Sprintf("no ARC size in %s", path))
+		return nil
+	}
+
+	now := time.Now()
+	bootID := c.bootID()
+	arc := &ZFSARCStats{
+		SizeBytes:   size,
+		TargetBytes: values["c"],
+		MinBytes:    values["c_min"],
+		MaxBytes:    values["c_max"],
+	}
+	if size > arc.MinBytes {
+		arc.ReclaimableBytes = size - arc.MinBytes
+	}
+	arc.HitsPerSec, arc.MissesPerSec, arc.HitRatioPercent = c.hitRatio("arc/", values["hits"], values["misses"], now, bootID)
+
+	// L2ARC counters exist without a cache device; only report them with one
+	if values["l2_size"] > 0 {
+		l2 := &ZFSL2ARCStats{
+			SizeBytes:      values["l2_size"],
+			AllocatedBytes: values["l2_asize"],
+			HeaderBytes:    values["l2_hdr_size"],
+		}
+		l2.HitsPerSec, l2.MissesPerSec, l2.HitRatioPercent = c.hitRatio("l2/", values["l2_hits"], values["l2_misses"], now, bootID)
+		l2.ReadBytesPerSec, _ = c.arcCounters.rate("l2/read_bytes", values["l2_read_bytes"], now, bootID)
+		l2.WriteBytesPerSec, _ = c.arcCounters.rate("l2/write_bytes", values["l2_write_bytes"], now, bootID)
+		arc.L2 = l2
+	}
+
+	c.arcCounters.prune(now)
+	return arc
+}
@@ -0,0 +1,103 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const arcstatsHeader = "13 1 0x01 123 33456 4512315432 80233567890\nname                            type data\n"
+
+func TestParseARCStats(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[string]uint64
+	}{
+		{
+			name: "counters and sizes",
+			data: arcstatsHeader +
+				"hits                            4    1838417\n" +
+				"misses                          4    20931\n" +
+				"size                            4    8589934592\n" +
+				"c_min                           4    1073741824\n",
+			want: map[string]uint64{"hits": 1838417, "misses": 20931, "size": 8589934592, "c_min": 1073741824},
+		},
+		{
+			name: "negative signed values are skipped",
+			data: arcstatsHeader +
+				"memory_available_bytes          3    -137438953\n" +
+				"arc_no_grow                     4    1\n",
+			want: map[string]uint64{"arc_no_grow": 1},
+		},
+		{
+			name: "header only",
+			data: arcstatsHeader,
+			want: map[string]uint64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseARCStats(tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseARCStats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveUsedMemory(t *testing.T) {
+	const gib = 1 << 30
+	tests := []struct {
+		name            string
+		usedBytes       uint64     // MemTotal - MemAvailable
+		arc             *[2]uint64 // size, c_min; nil for no ZFS
+		wantReclaimable uint64
+		wantEffective   uint64
+	}{
+		{name: "no ZFS", usedBytes: 10 * gib, wantEffective: 10 * gib},
+		{name: "ARC above c_min", usedBytes: 10 * gib, arc: &[2]uint64{6 * gib, 1 * gib}, wantReclaimable: 5 * gib, wantEffective: 5 * gib},
+		{name: "ARC at c_min", usedBytes: 10 * gib, arc: &[2]uint64{1 * gib, 1 * gib}, wantEffective: 10 * gib},
+		{name: "ARC below c_min", usedBytes: 10 * gib, arc: &[2]uint64{512 << 20, 1 * gib}, wantEffective: 10 * gib},
+		{name: "reclaimable larger than used", usedBytes: 2 * gib, arc: &[2]uint64{6 * gib, 1 * gib}, wantReclaimable: 5 * gib, wantEffective: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := t.TempDir()
+			const totalKB = 32 * gib / 1024
+			meminfo := fmt.Sprintf("MemTotal: %d kB\nMemAvailable: %d kB\n", totalKB, totalKB-tt.usedBytes/1024)
+			if err := os.WriteFile(filepath.Join(proc, "meminfo"), []byte(meminfo), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.arc != nil {
+				dir := filepath.Join(proc, "spl/kstat/zfs")
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				arcstats := arcstatsHeader + fmt.Sprintf("size 4 %d\nc_min 4 %d\n", tt.arc[0], tt.arc[1])
+				if err := os.WriteFile(filepath.Join(dir, "arcstats"), []byte(arcstats), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c := &Collector{procPath: proc, arcCounters: newCounterTracker()}
+
+			stats := c.collectMemory(context.Background())
+			if stats.UsedBytes == nil || *stats.UsedBytes != tt.usedBytes {
+				t.Fatalf("usedBytes = %v, want %d", stats.UsedBytes, tt.usedBytes)
+			}
+			if (stats.ZFSARC != nil) != (tt.arc != nil) {
+				t.Fatalf("zfsArc = %+v, want ZFS: %v", stats.ZFSARC, tt.arc != nil)
+			}
+			if stats.ZFSARC != nil && stats.ZFSARC.ReclaimableBytes != tt.wantReclaimable {
+				t.Errorf("reclaimableBytes = %d, want %d", stats.ZFSARC.ReclaimableBytes, tt.wantReclaimable)
+			}
+			if stats.EffectiveUsedBytes == nil || *stats.EffectiveUsedBytes != tt.wantEffective {
+				t.Errorf("effectiveUsedBytes = %v, want %d", stats.EffectiveUsedBytes, tt.wantEffective)
+			}
+		})
+	}
+}